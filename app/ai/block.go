@@ -0,0 +1,57 @@
+package ai
+
+import "github.com/slack-go/slack"
+
+const (
+	ASK_SHORTCUT = "ai-ask"
+	ASK_MODAL    = "ai-ask-modal"
+
+	QUESTION_ACTION = "ai-question"
+	QUESTION_INPUT  = "ai-question-input"
+
+	STOP_ACTION = "ai-stop"
+)
+
+// AskModal is the modal to ask the AI something from anywhere in Slack
+func AskModal() slack.ModalViewRequest {
+	input := slack.NewPlainTextInputBlockElement(
+		slack.NewTextBlockObject(
+			slack.PlainTextType,
+			"What do you want to know?",
+			false,
+			false,
+		),
+		QUESTION_ACTION,
+	)
+	input.Multiline = true
+
+	return slack.ModalViewRequest{
+		Type:       slack.VTModal,
+		CallbackID: ASK_MODAL,
+		Title:      slack.NewTextBlockObject(slack.PlainTextType, "Ask relax", false, false),
+		Submit:     slack.NewTextBlockObject(slack.PlainTextType, "Ask", false, false),
+		Close:      slack.NewTextBlockObject(slack.PlainTextType, "Cancel", false, false),
+		Blocks: slack.Blocks{
+			BlockSet: []slack.Block{
+				slack.NewInputBlock(
+					QUESTION_INPUT,
+					slack.NewTextBlockObject(slack.PlainTextType, "Question", false, false),
+					slack.NewTextBlockObject(slack.PlainTextType, "The answer will be sent to you in a direct message", false, false),
+					input,
+				),
+			},
+		},
+	}
+}
+
+// StopBlock is the button to stop the answer being generated
+func StopBlock(generation string) slack.Block {
+	return slack.NewActionBlock(
+		"",
+		slack.NewButtonBlockElement(
+			STOP_ACTION,
+			generation,
+			slack.NewTextBlockObject(slack.PlainTextType, "Stop generating", false, false),
+		).WithStyle(slack.StyleDanger),
+	)
+}
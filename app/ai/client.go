@@ -0,0 +1,42 @@
+package ai
+
+import (
+	"log"
+	"regexp"
+
+	"d-exclaimation.me/relax/config"
+	"d-exclaimation.me/relax/lib/f"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// azureDeployment is the default Azure deployment name for a model, which cannot have dots or colons
+var azureDeployment = regexp.MustCompile(`[.:]`)
+
+// clientConfig creates the client config from the environment, for OpenAI itself, Azure OpenAI,
+// or an OpenAI-compatible server (e.g. llama.cpp, Ollama, vLLM) at the base URL
+func clientConfig(token string) openai.ClientConfig {
+	var res openai.ClientConfig
+	switch config.Env.AIAPIType() {
+	case "azure", "azure_ad":
+		if config.Env.AIBaseURL() == "" {
+			log.Println("AI_BASE_URL is required for Azure OpenAI")
+		}
+		res = openai.DefaultAzureConfig(token, config.Env.AIBaseURL())
+		res.APIType = f.IfElse(config.Env.AIAPIType() == "azure", openai.APITypeAzure, openai.APITypeAzureAD)
+		res.APIVersion = config.Env.AIAPIVersion()
+		res.AzureModelMapperFunc = func(model string) string {
+			if deployment, ok := config.Env.AIDeployments()[model]; ok {
+				return deployment
+			}
+			return azureDeployment.ReplaceAllString(model, "")
+		}
+	default:
+		res = openai.DefaultConfig(token)
+		if config.Env.AIBaseURL() != "" {
+			res.BaseURL = config.Env.AIBaseURL()
+		}
+	}
+
+	res.OrgID = config.Env.AIOrg()
+	return res
+}
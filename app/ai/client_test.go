@@ -0,0 +1,222 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// request is what the fake server was asked for
+type request struct {
+	path   string
+	query  string
+	header http.Header
+	model  string
+	stream bool
+}
+
+// fakeServer imitates the chat completions endpoints of OpenAI and Azure OpenAI, answering every request with "pong"
+type fakeServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []request
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	srv := &fakeServer{}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := openai.ChatCompletionRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		srv.mu.Lock()
+		srv.requests = append(srv.requests, request{
+			path:   r.URL.Path,
+			query:  r.URL.RawQuery,
+			header: r.Header.Clone(),
+			model:  body.Model,
+			stream: body.Stream,
+		})
+		srv.mu.Unlock()
+
+		if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !body.Stream {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+				Model: body.Model,
+				Choices: []openai.ChatCompletionChoice{
+					{Message: openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "pong"}},
+				},
+			})
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, delta := range []string{"po", "ng"} {
+			chunk, _ := json.Marshal(openai.ChatCompletionStreamResponse{
+				Model: body.Model,
+				Choices: []openai.ChatCompletionStreamChoice{
+					{Delta: openai.ChatCompletionStreamChoiceDelta{Content: delta}},
+				},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// last gives back the latest request the fake server was asked for
+func (s *fakeServer) last(t *testing.T) request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("the fake server was never asked")
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// ask sends both a plain and a streamed chat completion to the fake server, checking the answer of both
+func ask(t *testing.T, client *openai.Client, model string) {
+	t.Helper()
+	req := openai.ChatCompletionRequest{
+		Model:    model,
+		Messages: []openai.ChatCompletionMessage{UserMessage("ping")},
+	}
+
+	res, err := client.CreateChatCompletion(context.Background(), req)
+	if err != nil {
+		t.Fatalf("chat completion failed: %s", err)
+	}
+	if res.Choices[0].Message.Content != "pong" {
+		t.Fatalf("expected pong, got %q", res.Choices[0].Message.Content)
+	}
+
+	req.Stream = true
+	stream, err := client.CreateChatCompletionStream(context.Background(), req)
+	if err != nil {
+		t.Fatalf("streamed chat completion failed: %s", err)
+	}
+	defer stream.Close()
+
+	answer := ""
+	for {
+		res, err := stream.Recv()
+		if err != nil {
+			break
+		}
+		answer += res.Choices[0].Delta.Content
+	}
+	if answer != "pong" {
+		t.Fatalf("expected pong streamed, got %q", answer)
+	}
+}
+
+func TestClientConfigOpenAI(t *testing.T) {
+	srv := newFakeServer(t)
+	t.Setenv("AI_API_TYPE", "")
+	t.Setenv("AI_BASE_URL", "")
+	t.Setenv("AI_ORG", "org-relax")
+
+	cfg := clientConfig("sk-token")
+	if cfg.BaseURL != "https://api.openai.com/v1" {
+		t.Fatalf("expected the OpenAI base URL, got %s", cfg.BaseURL)
+	}
+
+	// Never talk to OpenAI itself, only check what would be sent to it
+	cfg.BaseURL = srv.URL + "/v1"
+	ask(t, openai.NewClientWithConfig(cfg), "gpt-4")
+
+	req := srv.last(t)
+	if req.path != "/v1/chat/completions" || !req.stream || req.model != "gpt-4" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if req.header.Get("Authorization") != "Bearer sk-token" {
+		t.Fatalf("expected a bearer token, got %q", req.header.Get("Authorization"))
+	}
+	if req.header.Get("OpenAI-Organization") != "org-relax" {
+		t.Fatalf("expected the organization, got %q", req.header.Get("OpenAI-Organization"))
+	}
+}
+
+func TestClientConfigBaseURL(t *testing.T) {
+	srv := newFakeServer(t)
+	t.Setenv("AI_API_TYPE", "openai")
+	t.Setenv("AI_BASE_URL", srv.URL+"/v1")
+	t.Setenv("AI_ORG", "")
+
+	ask(t, openai.NewClientWithConfig(clientConfig("local-token")), "llama3:8b")
+
+	req := srv.last(t)
+	if req.path != "/v1/chat/completions" || req.model != "llama3:8b" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if req.header.Get("Authorization") != "Bearer local-token" {
+		t.Fatalf("expected a bearer token, got %q", req.header.Get("Authorization"))
+	}
+	if req.header.Get("OpenAI-Organization") != "" {
+		t.Fatalf("expected no organization, got %q", req.header.Get("OpenAI-Organization"))
+	}
+}
+
+func TestClientConfigAzure(t *testing.T) {
+	srv := newFakeServer(t)
+	t.Setenv("AI_API_TYPE", "azure")
+	t.Setenv("AI_BASE_URL", srv.URL)
+	t.Setenv("AI_API_VERSION", "2024-02-01")
+	t.Setenv("AI_DEPLOYMENTS", "gpt-4 = relax-gpt4")
+	t.Setenv("AI_ORG", "")
+
+	client := openai.NewClientWithConfig(clientConfig("azure-key"))
+
+	for model, deployment := range map[string]string{
+		"gpt-4":         "relax-gpt4",
+		"gpt-3.5-turbo": "gpt-35-turbo",
+	} {
+		ask(t, client, model)
+
+		req := srv.last(t)
+		if req.path != "/openai/deployments/"+deployment+"/chat/completions" {
+			t.Fatalf("expected %s to be sent to the %s deployment, got %s", model, deployment, req.path)
+		}
+		if req.query != "api-version=2024-02-01" {
+			t.Fatalf("expected the API version, got %q", req.query)
+		}
+		if req.header.Get("api-key") != "azure-key" || req.header.Get("Authorization") != "" {
+			t.Fatalf("expected only the api-key header, got %v", req.header)
+		}
+	}
+}
+
+func TestClientConfigAzureAD(t *testing.T) {
+	srv := newFakeServer(t)
+	t.Setenv("AI_API_TYPE", "azure_ad")
+	t.Setenv("AI_BASE_URL", srv.URL)
+	t.Setenv("AI_API_VERSION", "")
+	t.Setenv("AI_DEPLOYMENTS", "")
+	t.Setenv("AI_ORG", "")
+
+	ask(t, openai.NewClientWithConfig(clientConfig("ad-token")), "gpt-4")
+
+	req := srv.last(t)
+	if req.path != "/openai/deployments/gpt-4/chat/completions" || req.query != "api-version=2023-05-15" {
+		t.Fatalf("unexpected request %+v", req)
+	}
+	if req.header.Get("Authorization") != "Bearer ad-token" || req.header.Get("api-key") != "" {
+		t.Fatalf("expected only a bearer token, got %v", req.header)
+	}
+}
@@ -0,0 +1,102 @@
+package ai
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrNotGenerating is when there is no answer being generated to stop (e.g. it is already done)
+	ErrNotGenerating = errors.New("no answer is being generated")
+
+	// ErrNotAsker is when someone else than the one who asked tries to stop the answer
+	ErrNotAsker = errors.New("only the one who asked can stop the answer")
+)
+
+// generation is an answer being generated, which the one who asked can stop
+type generation struct {
+	asker  string
+	cancel context.CancelFunc
+}
+
+// generations keeps the answers being generated by their ID, acting as a concurrent-safe actor
+type generations struct {
+	starter chan struct {
+		id         string
+		generation generation
+	}
+	finisher chan string
+	stopper  chan struct {
+		id    string
+		asker string
+		out   chan error
+	}
+}
+
+// newGenerations creates the registry of answers being generated, and runs the actor
+func newGenerations() *generations {
+	g := &generations{
+		starter: make(chan struct {
+			id         string
+			generation generation
+		}),
+		finisher: make(chan string),
+		stopper: make(chan struct {
+			id    string
+			asker string
+			out   chan error
+		}),
+	}
+
+	go func() {
+		running := make(map[string]generation)
+		for {
+			select {
+			case s := <-g.starter:
+				running[s.id] = s.generation
+			case id := <-g.finisher:
+				delete(running, id)
+			case s := <-g.stopper:
+				gen, ok := running[s.id]
+				switch {
+				case !ok:
+					s.out <- ErrNotGenerating
+				case gen.asker != s.asker:
+					s.out <- ErrNotAsker
+				default:
+					gen.cancel()
+					delete(running, s.id)
+					s.out <- nil
+				}
+			}
+		}
+	}()
+
+	return g
+}
+
+// Generate starts an answer with the ID (e.g. the message it is written to) asked by the user, giving back the context
+// for the request which is cancelled when stopped, and the function to call once the answer is done
+func (l *LLM) Generate(ctx context.Context, id string, asker string) (context.Context, func()) {
+	generating, cancel := context.WithCancel(ctx)
+	l.generations.starter <- struct {
+		id         string
+		generation generation
+	}{id, generation{asker: asker, cancel: cancel}}
+
+	return generating, func() {
+		l.generations.finisher <- id
+		cancel()
+	}
+}
+
+// Stop cancels the answer with the ID, as long as it is the one who asked for it
+func (l *LLM) Stop(id string, asker string) error {
+	out := make(chan error)
+	l.generations.stopper <- struct {
+		id    string
+		asker string
+		out   chan error
+	}{id, asker, out}
+	return <-out
+}
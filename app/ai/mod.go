@@ -4,94 +4,59 @@ import (
 	"context"
 	"errors"
 	"io"
+	"log"
 	"time"
 
 	"d-exclaimation.me/relax/config"
+	"d-exclaimation.me/relax/lib/f"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 // Conversation is a struct that holds the conversation history for the LLM to use as context
 type Conversation struct {
 	start    time.Time
+	seeded   bool
+	persona  string
 	messages []openai.ChatCompletionMessage
 }
 
-// LLM is a struct that holds the AI LLM model and act as a concurrent-safe actor to handle the conversation history
-type LLM struct {
-	model         *openai.Client
-	conversations map[string]Conversation
-	setter        chan struct {
-		userId       string
-		conversation Conversation
-	}
-	getter chan struct {
-		userId string
-		out    chan Conversation
-	}
-}
+// Message is a single message in a conversation
+type Message = openai.ChatCompletionMessage
 
-// New is a constructor for the LLM struct and run the actor
-func New(token string) *LLM {
-	l := LLM{
-		model:         openai.NewClient(token),
-		conversations: make(map[string]Conversation),
-		setter: make(chan struct {
-			userId       string
-			conversation Conversation
-		}),
-		getter: make(chan struct {
-			userId string
-			out    chan Conversation
-		}),
-	}
+// History gives the messages so far to start a new conversation from (e.g. an existing thread)
+type History func() []Message
 
-	go func() {
-		for {
-			select {
-			case s := <-l.setter:
-				l.conversations[s.userId] = s.conversation
-			case g := <-l.getter:
-				conversation, ok := l.conversations[g.userId]
-				if !ok || time.Since(conversation.start) > 5*time.Minute {
-					conversation = Conversation{
-						start: time.Now(),
-						messages: []openai.ChatCompletionMessage{
-							{
-								Role:    openai.ChatMessageRoleSystem,
-								Content: config.Env.AIContext(),
-							},
-						},
-					}
-				}
-				g.out <- conversation
-			}
-		}
-	}()
+// UserMessage creates a message from the user
+func UserMessage(content string) Message {
+	return Message{Role: openai.ChatMessageRoleUser, Content: content}
+}
 
-	return &l
+// AssistantMessage creates a message from the AI itself
+func AssistantMessage(content string) Message {
+	return Message{Role: openai.ChatMessageRoleAssistant, Content: content}
 }
 
-// Set is a setter for the conversation history through the actor
-func (l *LLM) Set(userId string, conversation Conversation) {
-	l.setter <- struct {
-		userId       string
-		conversation Conversation
-	}{userId, conversation}
+// LLM is a struct that holds the AI LLM model and the store for the conversation history,
+// keyed by either the thread or the user
+type LLM struct {
+	model       *openai.Client
+	store       Store
+	generations *generations
 }
 
-// Get is a getter for the conversation history through the actor
-func (l *LLM) Get(userId string) Conversation {
-	out := make(chan Conversation)
-	l.getter <- struct {
-		userId string
-		out    chan Conversation
-	}{userId, out}
-	return <-out
+// New is a constructor for the LLM struct, talking to the API configured in the environment
+// and keeping the conversations in the store
+func New(token string, store Store) *LLM {
+	return &LLM{
+		model:       openai.NewClientWithConfig(clientConfig(token)),
+		store:       store,
+		generations: newGenerations(),
+	}
 }
 
-// ClearHistory is a function to clear the conversation history for a user through the actor
-func (l *LLM) ClearHistory(userId string) {
-	l.Set(userId, Conversation{
+// fresh creates a new conversation with only the system prompt
+func fresh() Conversation {
+	return Conversation{
 		start: time.Now(),
 		messages: []openai.ChatCompletionMessage{
 			{
@@ -99,67 +64,211 @@ func (l *LLM) ClearHistory(userId string) {
 				Content: config.Env.AIContext(),
 			},
 		},
-	})
+	}
+}
+
+// Set is a setter for the conversation history through the store
+func (l *LLM) Set(key string, conversation Conversation) {
+	l.store.Save(key, conversation)
+}
+
+// Get is a getter for the conversation history through the store, where a missing or expired one starts fresh
+func (l *LLM) Get(key string) Conversation {
+	conversation, ok := l.store.Load(key)
+	if !ok || time.Since(conversation.start) > lifetime {
+		return fresh()
+	}
+	return conversation
+}
+
+// Has returns true if there is a conversation going on for the key, with anything besides the system prompt
+func (l *LLM) Has(key string) bool {
+	conversation, ok := l.store.Load(key)
+	if !ok || time.Since(conversation.start) > lifetime {
+		return false
+	}
+	return f.Some(conversation.messages, func(m Message) bool { return m.Role != openai.ChatMessageRoleSystem })
+}
+
+// ClearHistory is a function to clear the conversation history for a thread / user through the store
+func (l *LLM) ClearHistory(key string) {
+	conversation := fresh()
+	conversation.seeded = true
+	l.Set(key, conversation)
+}
+
+// persona gives back the persona of the conversation, or picks the one for the asker (with its system prompt)
+// if the conversation just started
+func (l *LLM) persona(conversation *Conversation, asker Asker) Persona {
+	if conversation.persona != "" {
+		return named(conversation.persona)
+	}
+
+	persona := Resolve(asker)
+	conversation.persona = persona.Name
+	system := Message{Role: openai.ChatMessageRoleSystem, Content: persona.Prompt}
+	if len(conversation.messages) > 0 && conversation.messages[0].Role == openai.ChatMessageRoleSystem {
+		conversation.messages[0] = system
+	} else {
+		conversation.messages = append([]Message{system}, conversation.messages...)
+	}
+	return persona
 }
 
 // StreamChat is a function to stream the chat response from the AI LLM model
-// It returns a channel of string that will be batched and throlled for every 1.5 seconds (40 emits/minute)
-func (l *LLM) StreamChat(userId string, event string) (<-chan string, error) {
-	background := context.Background()
+// A new conversation starts from the history (if any) with the asker's persona, otherwise it continues from the previous messages
+// It returns a channel of events with the answer so far on every delta (left to the caller to pace), ending with either
+// the whole answer or why it failed, where cancelling the context stops the answer
+func (l *LLM) StreamChat(ctx context.Context, key string, asker Asker, event string, history History, options Options) <-chan StreamEvent {
+	// The AI is not asked at all once the asker used up their tokens
+	if spend, over := overQuota(asker); over {
+		stream := make(chan StreamEvent, 1)
+		stream <- OverQuota{Spend: spend}
+		close(stream)
+		return stream
+	}
 
-	prev := l.Get(userId)
+	prev := l.Get(key)
+
+	// The persona is picked for the one asking when the conversation starts, and kept until it ends
+	persona := l.persona(&prev, asker)
+	options = persona.apply(options)
+
+	if !prev.seeded && history != nil {
+		prev.messages = append(prev.messages, history()...)
+	}
+	prev.seeded = true
 	prev.messages = append(prev.messages, openai.ChatCompletionMessage{
 		Role:    openai.ChatMessageRoleUser,
 		Content: event,
 	})
 
-	deltas, err := l.model.CreateChatCompletionStream(background, openai.ChatCompletionRequest{
-		Model:           openai.GPT3Dot5Turbo,
-		Temperature:     1,
-		PresencePenalty: 2,
-		Messages:        prev.messages,
-		Stream:          true,
-	})
-	if err != nil {
-		return nil, err
+	// Keep the history within the model's context window
+	prev.messages = l.fit(ctx, asker, prev.messages, options.Model)
+
+	// The tools are left out once the AI called too many of them, so it has to answer
+	request := func(tools bool) openai.ChatCompletionRequest {
+		return openai.ChatCompletionRequest{
+			Model:            options.Model,
+			Temperature:      options.Temperature,
+			PresencePenalty:  options.PresencePenalty,
+			FrequencyPenalty: options.FrequencyPenalty,
+			Messages:         prev.messages,
+			Functions:        f.IfElse(tools, options.Tools.definitions(), nil),
+			Stream:           true,
+		}
 	}
 
-	stream := make(chan string)
+	stream := make(chan StreamEvent)
 
 	go func() {
+		defer close(stream)
+
+		// What the AI was given and what it wrote, recorded for the asker however the answer ends
+		spent, written := Usage{}, ""
+		defer func() {
+			if written != "" {
+				spent.Completion += Tokens(AssistantMessage(written))
+			}
+			record(asker, spent)
+		}()
+
+		send := func(tools bool) (*openai.ChatCompletionStream, error) {
+			req := request(tools)
+			spent.Prompt += promptTokens(req)
+			return l.model.CreateChatCompletionStream(ctx, req)
+		}
+
 		answer := ""
-		last := time.Now().Add(-250 * time.Millisecond)
 
-		for {
-			response, err := deltas.Recv()
-			if errors.Is(err, io.EOF) {
-				break
+		// fail ends the answer with what was answered so far, telling apart a stopped or rate limited one
+		fail := func(err error) {
+			log.Printf("Failed to stream the answer for %s: %s\n", key, err.Error())
+			switch {
+			case ctx.Err() != nil:
+				stream <- Failed{Answer: answer, Err: ctx.Err()}
+			case rateLimited(err):
+				stream <- RateLimited{Answer: answer, Err: err}
+			default:
+				stream <- Failed{Answer: answer, Err: err}
 			}
+		}
 
-			if err != nil {
+		deltas, err := send(true)
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		for calls := 0; ; calls++ {
+			// The function call the AI asks for, which comes in pieces like the content
+			call := openai.FunctionCall{}
+
+			for {
+				response, err := deltas.Recv()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+
+				if err != nil {
+					deltas.Close()
+					fail(err)
+					return
+				}
+
+				if len(response.Choices) < 1 {
+					continue
+				}
+
+				delta := response.Choices[0].Delta
+				if delta.FunctionCall != nil {
+					call.Name += delta.FunctionCall.Name
+					call.Arguments += delta.FunctionCall.Arguments
+					written += delta.FunctionCall.Name + delta.FunctionCall.Arguments
+				}
+
+				if delta.Content != "" {
+					answer += delta.Content
+					written += delta.Content
+					stream <- Delta{Answer: answer}
+				}
+			}
+			deltas.Close()
+
+			if call.Name == "" {
 				break
 			}
 
-			answer += response.Choices[0].Delta.Content
+			// Give the result of the tool back to the AI, and let it carry on
+			prev.messages = append(prev.messages,
+				openai.ChatCompletionMessage{
+					Role:         openai.ChatMessageRoleAssistant,
+					Content:      answer,
+					FunctionCall: &call,
+				},
+				openai.ChatCompletionMessage{
+					Role:    openai.ChatMessageRoleFunction,
+					Name:    call.Name,
+					Content: options.Tools.run(call),
+				},
+			)
+			answer = ""
 
-			if time.Since(last) > 1500*time.Millisecond {
-				stream <- answer
-				last = time.Now()
+			deltas, err = send(calls+1 < maxToolCalls)
+			if err != nil {
+				fail(err)
+				return
 			}
 		}
 
-		time.Sleep(1500*time.Millisecond - time.Since(last))
-		stream <- answer
-
 		prev.messages = append(prev.messages, openai.ChatCompletionMessage{
 			Role:    openai.ChatMessageRoleAssistant,
 			Content: answer,
 		})
 
-		l.Set(userId, prev)
-
-		close(stream)
+		l.Set(key, prev)
+		stream <- Done{Answer: answer}
 	}()
 
-	return stream, nil
+	return stream
 }
@@ -0,0 +1,70 @@
+package ai
+
+import (
+	"d-exclaimation.me/relax/config"
+	"d-exclaimation.me/relax/lib/f"
+)
+
+// Options are the settings of the AI model for a single chat
+type Options struct {
+	Model            string
+	Temperature      float32
+	PresencePenalty  float32
+	FrequencyPenalty float32
+
+	// Tools are what the AI can call to look up or do something before answering
+	Tools Tools
+
+	// modelPicked and stylePicked are whether the model or style was picked for the question, over the persona's
+	modelPicked bool
+	stylePicked bool
+}
+
+// Defaults are the options from the environment
+func Defaults() Options {
+	return Options{
+		Model:            config.Env.AIModel(),
+		Temperature:      config.Env.AITemperature(),
+		PresencePenalty:  config.Env.AIPresencePenalty(),
+		FrequencyPenalty: config.Env.AIFrequencyPenalty(),
+	}
+}
+
+// WithModel uses the model instead
+func (o Options) WithModel(model string) Options {
+	o.Model = model
+	o.modelPicked = true
+	return o
+}
+
+// WithTools lets the AI call the tools
+func (o Options) WithTools(tools ...Tool) Options {
+	o.Tools = append(append(Tools{}, o.Tools...), tools...)
+	return o
+}
+
+// Precise makes the answers focused and consistent
+func (o Options) Precise() Options {
+	o.Temperature = 0.2
+	o.PresencePenalty = 0
+	o.FrequencyPenalty = 0
+	o.stylePicked = true
+	return o
+}
+
+// Creative makes the answers more varied and surprising
+func (o Options) Creative() Options {
+	o.Temperature = 1.4
+	o.stylePicked = true
+	return o
+}
+
+// Models returns the models allowed to be picked
+func Models() []string {
+	return config.Env.AIModels()
+}
+
+// Allowed returns true if the model is allowed to be picked
+func Allowed(model string) bool {
+	return f.IsMember(Models(), model)
+}
@@ -0,0 +1,185 @@
+package ai
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+
+	"d-exclaimation.me/relax/config"
+	"d-exclaimation.me/relax/lib/f"
+	"d-exclaimation.me/relax/lib/kv"
+)
+
+const (
+	// DEFAULT_PERSONA is the persona used when none is assigned, which can be redefined instead of the environment's
+	DEFAULT_PERSONA = "default"
+
+	// personasKey is the hash of every persona defined in the KV store, by their name
+	personasKey = "ai:personas"
+)
+
+// Scope is who a persona is assigned to
+type Scope string
+
+const (
+	// UserScope is a persona for every conversation with the user, which wins over the channel's
+	UserScope Scope = "user"
+
+	// ChannelScope is a persona for every conversation in the channel
+	ChannelScope Scope = "channel"
+)
+
+// Asker is who is asking the AI, and where
+type Asker struct {
+	UserID  string
+	Channel string
+}
+
+// Persona is how the AI behaves in a conversation, picked when the conversation starts
+type Persona struct {
+	Name string `json:"name"`
+
+	// Prompt is the system prompt of the conversation
+	Prompt string `json:"prompt"`
+
+	// Model and Temperature are used unless picked for the question, where empty ones are left to the defaults
+	Model       string   `json:"model,omitempty"`
+	Temperature *float32 `json:"temperature,omitempty"`
+
+	// Tools are the names of the tools the AI can call, where nil allows every tool and empty allows none
+	Tools []string `json:"tools"`
+}
+
+// apply uses the model, temperature, and tools of the persona for the options, unless the model or style was picked
+func (p Persona) apply(options Options) Options {
+	if p.Model != "" && !options.modelPicked {
+		options.Model = p.Model
+	}
+	if p.Temperature != nil && !options.stylePicked {
+		options.Temperature = *p.Temperature
+	}
+	if p.Tools != nil {
+		options.Tools = f.Filter(options.Tools, func(tool Tool) bool { return f.IsMember(p.Tools, tool.name) })
+	}
+	return options
+}
+
+// defaultPersona is the persona from the environment
+func defaultPersona() Persona {
+	return Persona{Name: DEFAULT_PERSONA, Prompt: config.Env.AIContext()}
+}
+
+// Define saves the persona in the KV store, replacing the one with the same name
+func Define(persona Persona) error {
+	data, err := json.Marshal(persona)
+	if err != nil {
+		return err
+	}
+	_, err = kv.HSet(personasKey, persona.Name, string(data)).Await()
+	return err
+}
+
+// Undefine deletes the persona from the KV store, returning false if there was none with the name
+func Undefine(name string) (bool, error) {
+	res, err := kv.HDel(personasKey, name).Await()
+	if err != nil {
+		return false, err
+	}
+	return res.Result > 0, nil
+}
+
+// FindPersona gives back the persona by its name, if there is one
+func FindPersona(name string) (Persona, bool, error) {
+	res, err := kv.HGet(personasKey, name).Await()
+	if err != nil {
+		return Persona{}, false, err
+	}
+	if res.Result == "" {
+		return Persona{}, false, nil
+	}
+
+	var persona Persona
+	if err := json.Unmarshal([]byte(res.Result), &persona); err != nil {
+		return Persona{}, false, err
+	}
+	return persona, true, nil
+}
+
+// Personas gives back every persona defined sorted by their name, after the default one if it was not redefined
+func Personas() ([]Persona, error) {
+	res, err := kv.HGetAll(personasKey).Await()
+	if err != nil {
+		return nil, err
+	}
+
+	personas := []Persona{}
+	for name, data := range res.Result {
+		var persona Persona
+		if err := json.Unmarshal([]byte(data), &persona); err != nil {
+			log.Printf("Failed to read the persona %s: %s\n", name, err.Error())
+			continue
+		}
+		personas = append(personas, persona)
+	}
+	sort.Slice(personas, func(i, j int) bool { return personas[i].Name < personas[j].Name })
+
+	if !f.Some(personas, func(p Persona) bool { return p.Name == DEFAULT_PERSONA }) {
+		personas = append([]Persona{defaultPersona()}, personas...)
+	}
+	return personas, nil
+}
+
+// Assign picks the persona for the user or channel, where an empty name goes back to the default
+func Assign(scope Scope, id string, name string) error {
+	if name == "" {
+		_, err := kv.Del(assignmentKey(scope, id)).Await()
+		return err
+	}
+	_, err := kv.Set(assignmentKey(scope, id), name).Await()
+	return err
+}
+
+// Assigned gives back the name of the persona picked for the user or channel, or empty if there is none
+func Assigned(scope Scope, id string) (string, error) {
+	res, err := kv.Get(assignmentKey(scope, id)).Await()
+	if err != nil {
+		return "", err
+	}
+	return res.Result, nil
+}
+
+// Resolve gives back the persona for the one asking, which is the user's, the channel's, or otherwise the default
+func Resolve(asker Asker) Persona {
+	res, err := kv.GetAll(assignmentKey(UserScope, asker.UserID), assignmentKey(ChannelScope, asker.Channel)).Await()
+	if err != nil {
+		log.Printf("Failed to look up the persona for %s in %s: %s\n", asker.UserID, asker.Channel, err.Error())
+		return named(DEFAULT_PERSONA)
+	}
+
+	for _, assigned := range res {
+		if assigned.Result != "" {
+			return named(assigned.Result)
+		}
+	}
+	return named(DEFAULT_PERSONA)
+}
+
+// assignmentKey is where the persona picked for the user or channel is kept
+func assignmentKey(scope Scope, id string) string {
+	return "ai:persona:" + string(scope) + ":" + id
+}
+
+// named gives back the persona by its name, falling back to the default if it is gone (e.g. deleted)
+func named(name string) Persona {
+	persona, ok, err := FindPersona(name)
+	if err != nil {
+		log.Printf("Failed to look up the persona %s: %s\n", name, err.Error())
+	}
+	if ok {
+		return persona
+	}
+	if name != DEFAULT_PERSONA {
+		return named(DEFAULT_PERSONA)
+	}
+	return defaultPersona()
+}
@@ -0,0 +1,207 @@
+package ai
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"d-exclaimation.me/relax/lib/kv"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	// lifetime is how long a conversation lasts before starting fresh
+	lifetime = 5 * time.Minute
+
+	// maxMessages is the most messages kept in a stored conversation, besides the system prompt
+	maxMessages = 50
+
+	// maxBytes is the most bytes of a serialized conversation in the KV store
+	maxBytes = 64 * 1024
+)
+
+// Store keeps the conversations by their key
+type Store interface {
+	// Load gives back the conversation by its key, if there is one
+	Load(key string) (Conversation, bool)
+
+	// Save keeps the conversation by its key
+	Save(key string, conversation Conversation)
+}
+
+// memory is a store that keeps the conversations in-process and act as a concurrent-safe actor
+type memory struct {
+	conversations map[string]Conversation
+	setter        chan struct {
+		key          string
+		conversation Conversation
+	}
+	getter chan struct {
+		key string
+		out chan struct {
+			conversation Conversation
+			ok           bool
+		}
+	}
+}
+
+// Memory creates a store that keeps the conversations in-process, which are lost on restart
+func Memory() Store {
+	m := memory{
+		conversations: make(map[string]Conversation),
+		setter: make(chan struct {
+			key          string
+			conversation Conversation
+		}),
+		getter: make(chan struct {
+			key string
+			out chan struct {
+				conversation Conversation
+				ok           bool
+			}
+		}),
+	}
+
+	go func() {
+		swept := time.Now()
+		for {
+			select {
+			case s := <-m.setter:
+				m.conversations[s.key] = s.conversation
+
+				// Forget the conversations that are no longer relevant every now and then
+				if time.Since(swept) > lifetime {
+					for key, conversation := range m.conversations {
+						if time.Since(conversation.start) > lifetime {
+							delete(m.conversations, key)
+						}
+					}
+					swept = time.Now()
+				}
+			case g := <-m.getter:
+				conversation, ok := m.conversations[g.key]
+				g.out <- struct {
+					conversation Conversation
+					ok           bool
+				}{conversation, ok}
+			}
+		}
+	}()
+
+	return &m
+}
+
+func (m *memory) Load(key string) (Conversation, bool) {
+	out := make(chan struct {
+		conversation Conversation
+		ok           bool
+	})
+	m.getter <- struct {
+		key string
+		out chan struct {
+			conversation Conversation
+			ok           bool
+		}
+	}{key, out}
+	res := <-out
+	return res.conversation, res.ok
+}
+
+func (m *memory) Save(key string, conversation Conversation) {
+	m.setter <- struct {
+		key          string
+		conversation Conversation
+	}{key, conversation}
+}
+
+// stored is the serialized form of a conversation in the KV store
+type stored struct {
+	Start    time.Time                      `json:"start"`
+	Seeded   bool                           `json:"seeded"`
+	Persona  string                         `json:"persona,omitempty"`
+	Messages []openai.ChatCompletionMessage `json:"messages"`
+}
+
+// store is a store that keeps the conversations in the KV store, shared between replicas and kept across restarts
+type store struct{}
+
+// KV creates a store that keeps the conversations in the KV store, which expire after their lifetime
+// and are capped in size by dropping the oldest messages
+func KV() Store {
+	return store{}
+}
+
+func (store) Load(key string) (Conversation, bool) {
+	res, err := kv.Get("ai:conversation:" + key).Await()
+	if err != nil {
+		log.Printf("Failed to load the conversation %s: %s\n", key, err.Error())
+		return Conversation{}, false
+	}
+	if res.Result == "" {
+		return Conversation{}, false
+	}
+
+	var s stored
+	if err := json.Unmarshal([]byte(res.Result), &s); err != nil {
+		log.Printf("Failed to read the conversation %s: %s\n", key, err.Error())
+		return Conversation{}, false
+	}
+
+	return Conversation{start: s.Start, seeded: s.Seeded, persona: s.Persona, messages: s.Messages}, true
+}
+
+func (store) Save(key string, conversation Conversation) {
+	s := stored{
+		Start:    conversation.start,
+		Seeded:   conversation.seeded,
+		Persona:  conversation.persona,
+		Messages: capped(conversation.messages, maxMessages),
+	}
+
+	data, err := json.Marshal(s)
+	for err == nil && len(data) > maxBytes && len(s.Messages) > 1 {
+		s.Messages = capped(s.Messages, len(s.Messages)-2)
+		data, err = json.Marshal(s)
+	}
+	if err != nil {
+		log.Printf("Failed to write the conversation %s: %s\n", key, err.Error())
+		return
+	}
+
+	ttl := lifetime - time.Since(conversation.start)
+	if ttl < time.Second {
+		return
+	}
+
+	_, err = kv.SetEX("ai:conversation:"+key, string(data), ttl).Await()
+	if err != nil {
+		log.Printf("Failed to save the conversation %s: %s\n", key, err.Error())
+	}
+}
+
+// capped keeps the system prompt (if any) and the latest messages, up to the limit besides the system prompt,
+// never starting in the middle of a function call
+func capped(messages []openai.ChatCompletionMessage, limit int) []openai.ChatCompletionMessage {
+	if len(messages) < 1 || messages[0].Role != openai.ChatMessageRoleSystem {
+		if len(messages) <= limit {
+			return messages
+		}
+		return paired(messages[len(messages)-limit:])
+	}
+	return append([]openai.ChatCompletionMessage{messages[0]}, capped(messages[1:], limit)...)
+}
+
+// paired drops the function results at the start whose call was cut off, and a call at the start without its result,
+// so the call and its result are always kept or dropped together
+func paired(messages []openai.ChatCompletionMessage) []openai.ChatCompletionMessage {
+	for len(messages) > 0 {
+		first := messages[0]
+		orphan := first.Role == openai.ChatMessageRoleFunction ||
+			(first.FunctionCall != nil && (len(messages) < 2 || messages[1].Role != openai.ChatMessageRoleFunction))
+		if !orphan {
+			break
+		}
+		messages = messages[1:]
+	}
+	return messages
+}
@@ -0,0 +1,86 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+
+	"d-exclaimation.me/relax/lib/f"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// system creates a system message, like the prompt or a summary
+func system(content string) Message {
+	return Message{Role: openai.ChatMessageRoleSystem, Content: content}
+}
+
+// call creates a message from the AI calling the tool
+func call(name string) Message {
+	return Message{Role: openai.ChatMessageRoleAssistant, FunctionCall: &openai.FunctionCall{Name: name}}
+}
+
+// result creates the result of calling the tool
+func result(name string) Message {
+	return Message{Role: openai.ChatMessageRoleFunction, Name: name, Content: "r:" + name}
+}
+
+// labels names the messages, so a test failure shows which ones were kept
+func labels(messages []Message) []string {
+	return f.Map(messages, func(m Message) string {
+		if m.FunctionCall != nil {
+			return "c:" + m.FunctionCall.Name
+		}
+		return m.Content
+	})
+}
+
+func TestPaired(t *testing.T) {
+	tests := []struct {
+		name     string
+		messages []Message
+		kept     []string
+	}{
+		{name: "empty", messages: []Message{}, kept: []string{}},
+		{name: "already paired", messages: []Message{call("a"), result("a"), UserMessage("u")}, kept: []string{"c:a", "r:a", "u"}},
+		{name: "result without its call", messages: []Message{result("a"), UserMessage("u")}, kept: []string{"u"}},
+		{name: "several results without their calls", messages: []Message{result("a"), result("b"), AssistantMessage("x")}, kept: []string{"x"}},
+		{name: "call without its result", messages: []Message{call("a"), UserMessage("u")}, kept: []string{"u"}},
+		{name: "only a call", messages: []Message{call("a")}, kept: []string{}},
+		{name: "calls later on", messages: []Message{UserMessage("u"), call("a"), result("a")}, kept: []string{"u", "c:a", "r:a"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if kept := labels(paired(test.messages)); !reflect.DeepEqual(kept, test.kept) {
+				t.Fatalf("expected %v, got %v", test.kept, kept)
+			}
+		})
+	}
+}
+
+func TestCapped(t *testing.T) {
+	conversation := []Message{system("s"), UserMessage("u1"), call("a"), result("a"), AssistantMessage("a1"), UserMessage("u2")}
+
+	tests := []struct {
+		name     string
+		messages []Message
+		limit    int
+		kept     []string
+	}{
+		{name: "under the limit", messages: conversation, limit: 10, kept: []string{"s", "u1", "c:a", "r:a", "a1", "u2"}},
+		{name: "at the limit", messages: conversation, limit: 5, kept: []string{"s", "u1", "c:a", "r:a", "a1", "u2"}},
+		{name: "cut before a call", messages: conversation, limit: 4, kept: []string{"s", "c:a", "r:a", "a1", "u2"}},
+		{name: "cut between a call and its result", messages: conversation, limit: 3, kept: []string{"s", "a1", "u2"}},
+		{name: "latest only", messages: conversation, limit: 1, kept: []string{"s", "u2"}},
+		{name: "without a system prompt", messages: conversation[1:], limit: 3, kept: []string{"a1", "u2"}},
+		{name: "only a system prompt", messages: conversation[:1], limit: 3, kept: []string{"s"}},
+		{name: "empty", messages: []Message{}, limit: 3, kept: []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if kept := labels(capped(test.messages, test.limit)); !reflect.DeepEqual(kept, test.kept) {
+				t.Fatalf("expected %v, got %v", test.kept, kept)
+			}
+		})
+	}
+}
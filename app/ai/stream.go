@@ -0,0 +1,59 @@
+package ai
+
+import (
+	"errors"
+	"net/http"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// StreamEvent is what happens while the answer is streamed, which is either a Delta, Done, Failed, RateLimited, or OverQuota
+type StreamEvent interface {
+	streamEvent()
+}
+
+// Delta is the answer so far, while the AI is still going
+type Delta struct {
+	Answer string
+}
+
+// Done is the whole answer, which is always the last event unless the answer failed
+type Done struct {
+	Answer string
+}
+
+// Failed is when the answer stopped early (e.g. an error mid-stream, or stopped by the user), with what was answered so far
+type Failed struct {
+	Answer string
+	Err    error
+}
+
+// RateLimited is when the AI refuses to answer (any further) because of too many requests, with what was answered so far
+type RateLimited struct {
+	Answer string
+	Err    error
+}
+
+// OverQuota is when the user or the channel used up their tokens, so the AI is not asked at all
+type OverQuota struct {
+	Spend Spend
+}
+
+func (Delta) streamEvent()       {}
+func (Done) streamEvent()        {}
+func (Failed) streamEvent()      {}
+func (RateLimited) streamEvent() {}
+func (OverQuota) streamEvent()   {}
+
+// rateLimited returns true if the request to the AI was refused for being over the rate limit
+func rateLimited(err error) bool {
+	apiErr := &openai.APIError{}
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	reqErr := &openai.RequestError{}
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"d-exclaimation.me/relax/config"
+	"d-exclaimation.me/relax/lib/f"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	// summaryPrefix marks the system message that summarizes the history trimmed off
+	summaryPrefix = "Summary of the conversation so far: "
+
+	// summaryTokens is the most tokens of a summary
+	summaryTokens = 256
+)
+
+// Tokens approximates how many tokens the message takes, at around 4 characters per token
+// and a few more for the role and formatting of every message
+func Tokens(message Message) int {
+	return (len(message.Content)+3)/4 + 4
+}
+
+// promptTokens approximates how many tokens the request takes, including the tools described to the AI
+func promptTokens(request openai.ChatCompletionRequest) int {
+	tokens := f.SumBy(request.Messages, Tokens)
+	if len(request.Functions) > 0 {
+		functions, _ := json.Marshal(request.Functions)
+		tokens += (len(functions) + 3) / 4
+	}
+	return tokens
+}
+
+// trim drops the oldest messages until the conversation fits the budget, but always keeps the system prompt
+// (and summary) at the start and the latest message, giving back what was kept and what was dropped
+func trim(messages []Message, budget int) ([]Message, []Message) {
+	pinned := 0
+	for pinned < len(messages) && messages[pinned].Role == openai.ChatMessageRoleSystem {
+		pinned++
+	}
+
+	total := f.SumBy(messages, Tokens)
+	dropped := 0
+	for total > budget && len(messages)-pinned-dropped > 1 {
+		total -= Tokens(messages[pinned+dropped])
+		dropped++
+	}
+
+	if dropped == 0 {
+		return messages, nil
+	}
+
+	// A function call and its result are dropped together
+	rest := paired(messages[pinned+dropped:])
+	dropped = len(messages) - pinned - len(rest)
+
+	kept := append(append([]Message{}, messages[:pinned]...), rest...)
+	return kept, messages[pinned : pinned+dropped]
+}
+
+// fit trims the conversation to the token budget, and replaces the messages dropped with a summary if enabled
+func (l *LLM) fit(ctx context.Context, asker Asker, messages []Message, model string) []Message {
+	kept, dropped := trim(messages, config.Env.AIBudget())
+	if len(dropped) == 0 || !config.Env.AISummarize() {
+		return kept
+	}
+
+	// The previous summary (if any) is right after the system prompt, and is folded into the new one
+	previous, index, hasSummary := f.FindIndexOf(kept, func(m Message) bool {
+		return m.Role == openai.ChatMessageRoleSystem && strings.HasPrefix(m.Content, summaryPrefix)
+	})
+	if hasSummary {
+		dropped = append([]Message{previous}, dropped...)
+		kept = append(append([]Message{}, kept[:index]...), kept[index+1:]...)
+	}
+
+	summary, err := l.summarize(ctx, asker, dropped, model)
+	if err != nil {
+		log.Printf("Failed to summarize the conversation: %s\n", err.Error())
+		return kept
+	}
+
+	pinned := 0
+	for pinned < len(kept) && kept[pinned].Role == openai.ChatMessageRoleSystem {
+		pinned++
+	}
+
+	withSummary := append(append(append([]Message{}, kept[:pinned]...), Message{
+		Role:    openai.ChatMessageRoleSystem,
+		Content: summaryPrefix + summary,
+	}), kept[pinned:]...)
+
+	res, _ := trim(withSummary, config.Env.AIBudget())
+	return res
+}
+
+// summarize asks the model for a short summary of the messages, where the tokens spent are the asker's
+func (l *LLM) summarize(ctx context.Context, asker Asker, messages []Message, model string) (string, error) {
+	transcript := f.Map(messages, func(m Message) string {
+		return fmt.Sprintf("%s: %s", m.Role, strings.TrimPrefix(m.Content, summaryPrefix))
+	})
+
+	res, err := l.model.CreateChatCompletion(ctx, openai.ChatCompletionRequest{
+		Model:     model,
+		MaxTokens: summaryTokens,
+		Messages: []Message{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "Summarize the conversation below in a few sentences, keeping any names, facts, and decisions that may matter later on.",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: f.Text(transcript...),
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	record(asker, Usage{Prompt: res.Usage.PromptTokens, Completion: res.Usage.CompletionTokens})
+
+	if len(res.Choices) < 1 {
+		return "", fmt.Errorf("no summary given back")
+	}
+	return res.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,72 @@
+package ai
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrim(t *testing.T) {
+	// Every message takes 5 tokens as their contents are at most 4 characters, besides a call taking 4 without any
+	conversation := []Message{system("s"), UserMessage("u1"), call("a"), result("a"), AssistantMessage("a1"), UserMessage("u2")}
+	summarized := []Message{system("s"), system("sum"), UserMessage("u1"), AssistantMessage("a1"), UserMessage("u2")}
+
+	tests := []struct {
+		name     string
+		messages []Message
+		budget   int
+		kept     []string
+		dropped  []string
+	}{
+		{
+			name:     "within the budget",
+			messages: conversation,
+			budget:   100,
+			kept:     []string{"s", "u1", "c:a", "r:a", "a1", "u2"},
+			dropped:  []string{},
+		},
+		{
+			name:     "oldest first",
+			messages: conversation,
+			budget:   25,
+			kept:     []string{"s", "c:a", "r:a", "a1", "u2"},
+			dropped:  []string{"u1"},
+		},
+		{
+			name:     "a call with its result",
+			messages: conversation,
+			budget:   20,
+			kept:     []string{"s", "a1", "u2"},
+			dropped:  []string{"u1", "c:a", "r:a"},
+		},
+		{
+			name:     "down to the latest",
+			messages: conversation,
+			budget:   1,
+			kept:     []string{"s", "u2"},
+			dropped:  []string{"u1", "c:a", "r:a", "a1"},
+		},
+		{
+			name:     "summary kept",
+			messages: summarized,
+			budget:   15,
+			kept:     []string{"s", "sum", "u2"},
+			dropped:  []string{"u1", "a1"},
+		},
+		{
+			name:     "latest over the budget",
+			messages: []Message{UserMessage("u1")},
+			budget:   1,
+			kept:     []string{"u1"},
+			dropped:  []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kept, dropped := trim(test.messages, test.budget)
+			if !reflect.DeepEqual(labels(kept), test.kept) || !reflect.DeepEqual(labels(dropped), test.dropped) {
+				t.Fatalf("expected %v kept and %v dropped, got %v and %v", test.kept, test.dropped, labels(kept), labels(dropped))
+			}
+		})
+	}
+}
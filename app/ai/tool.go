@@ -0,0 +1,124 @@
+package ai
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"d-exclaimation.me/relax/lib/f"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// maxToolCalls is the most tools the AI can call before it has to answer
+const maxToolCalls = 3
+
+// ToolArgs are the arguments the AI gave to a tool
+type ToolArgs map[string]any
+
+// String returns the value of a string argument, or an empty string if not given
+func (a ToolArgs) String(name string) string {
+	res, _ := a[name].(string)
+	return res
+}
+
+// Int returns the value of an integer argument, or 0 if not given
+func (a ToolArgs) Int(name string) int {
+	res, _ := a[name].(float64)
+	return int(res)
+}
+
+// Tool is a function the AI can call to look up or do something, where the result is given back as JSON
+type Tool struct {
+	name        string
+	description string
+	params      map[string]jsonschema.Definition
+	required    []string
+	call        func(args ToolArgs) (any, error)
+}
+
+// NewTool creates a tool with the name and description the AI uses to decide when to call it
+func NewTool(name string, description string, call func(args ToolArgs) (any, error)) Tool {
+	return Tool{
+		name:        name,
+		description: description,
+		params:      make(map[string]jsonschema.Definition),
+		call:        call,
+	}
+}
+
+// Name is what the AI calls the tool by
+func (t Tool) Name() string {
+	return t.name
+}
+
+// Param adds an optional parameter to the tool
+func (t Tool) Param(name string, kind jsonschema.DataType, description string) Tool {
+	params := make(map[string]jsonschema.Definition, len(t.params)+1)
+	for k, v := range t.params {
+		params[k] = v
+	}
+	params[name] = jsonschema.Definition{Type: kind, Description: description}
+	t.params = params
+	return t
+}
+
+// Required marks the parameters as mandatory
+func (t Tool) Required(names ...string) Tool {
+	t.required = append(append([]string{}, t.required...), names...)
+	return t
+}
+
+// definition describes the tool to the AI
+func (t Tool) definition() openai.FunctionDefinition {
+	return openai.FunctionDefinition{
+		Name:        t.name,
+		Description: t.description,
+		Parameters: jsonschema.Definition{
+			Type:       jsonschema.Object,
+			Properties: t.params,
+			Required:   t.required,
+		},
+	}
+}
+
+// Tools is the registry of tools the AI can call
+type Tools []Tool
+
+// definitions describes every tool to the AI
+func (t Tools) definitions() []openai.FunctionDefinition {
+	return f.Map(t, func(tool Tool) openai.FunctionDefinition { return tool.definition() })
+}
+
+// run calls the tool the AI asked for, and gives back the result (or the error) as JSON for the AI to read
+func (t Tools) run(call openai.FunctionCall) string {
+	failure := func(err error) string {
+		log.Printf("Tool %s gives back %s\n", call.Name, err.Error())
+		res, _ := json.Marshal(map[string]string{"error": err.Error()})
+		return string(res)
+	}
+
+	named := func(tool Tool) bool { return tool.name == call.Name }
+	if !f.Some(t, named) {
+		return failure(fmt.Errorf("no tool named %s", call.Name))
+	}
+	tool, _ := f.First(t, named)
+
+	args := ToolArgs{}
+	if call.Arguments != "" {
+		if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+			return failure(fmt.Errorf("invalid arguments: %w", err))
+		}
+	}
+
+	result, err := tool.call(args)
+	if err != nil {
+		return failure(err)
+	}
+
+	res, err := json.Marshal(result)
+	if err != nil {
+		return failure(err)
+	}
+	return string(res)
+}
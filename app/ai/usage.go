@@ -0,0 +1,171 @@
+package ai
+
+import (
+	"log"
+	"time"
+
+	"d-exclaimation.me/relax/config"
+	"d-exclaimation.me/relax/lib/f"
+	"d-exclaimation.me/relax/lib/kv"
+)
+
+// Period is how long the tokens spent are counted together
+type Period string
+
+const (
+	// Daily counts the tokens spent in a day (in UTC)
+	Daily Period = "day"
+
+	// Monthly counts the tokens spent in a month (in UTC)
+	Monthly Period = "month"
+)
+
+// bucket is the name of the period the time falls in
+func (p Period) bucket(t time.Time) string {
+	return t.UTC().Format(f.IfElse(p == Daily, "2006-01-02", "2006-01"))
+}
+
+// retention is how long the tokens spent in a period are kept, long enough to still see the previous one
+func (p Period) retention() time.Duration {
+	return f.IfElse(p == Daily, 35*24*time.Hour, 400*24*time.Hour)
+}
+
+// Resets gives back when the period the time falls in ends
+func (p Period) Resets(t time.Time) time.Time {
+	t = t.UTC()
+	if p == Daily {
+		return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Usage is how many tokens were spent, which is approximated for the streamed answers
+type Usage struct {
+	Prompt     int
+	Completion int
+}
+
+// Total is every token spent
+func (u Usage) Total() int {
+	return u.Prompt + u.Completion
+}
+
+// Spend is what a user or a channel spent in the current period, and the quota they have
+type Spend struct {
+	Scope  Scope
+	ID     string
+	Period Period
+	Usage  Usage
+
+	// Quota is the most tokens that can be spent in the period, or 0 if there is no limit
+	Quota int
+}
+
+// Over returns true if the quota is used up
+func (s Spend) Over() bool {
+	return s.Quota > 0 && s.Usage.Total() >= s.Quota
+}
+
+// quota is the most tokens that can be spent by a user or in a channel in the period, or 0 if there is no limit
+func quota(scope Scope, period Period) int {
+	switch {
+	case scope == UserScope && period == Daily:
+		return config.Env.AIUserDailyQuota()
+	case scope == UserScope && period == Monthly:
+		return config.Env.AIUserMonthlyQuota()
+	case scope == ChannelScope && period == Daily:
+		return config.Env.AIChannelDailyQuota()
+	case scope == ChannelScope && period == Monthly:
+		return config.Env.AIChannelMonthlyQuota()
+	}
+	return 0
+}
+
+// spends are the user's and the channel's spends of today and this month, without the usage yet
+func (a Asker) spends() []Spend {
+	spends := []Spend{}
+	for _, owner := range []struct {
+		scope Scope
+		id    string
+	}{{UserScope, a.UserID}, {ChannelScope, a.Channel}} {
+		if owner.id == "" {
+			continue
+		}
+		for _, period := range []Period{Daily, Monthly} {
+			spends = append(spends, Spend{Scope: owner.scope, ID: owner.id, Period: period, Quota: quota(owner.scope, period)})
+		}
+	}
+	return spends
+}
+
+// usageKey is where the tokens spent by a user or in a channel in the period the time falls in are kept
+func usageKey(scope Scope, id string, period Period, at time.Time) string {
+	return "ai:usage:" + string(scope) + ":" + id + ":" + string(period) + ":" + period.bucket(at)
+}
+
+// Spending gives back what the user and the channel spent today and this month
+func Spending(asker Asker) ([]Spend, error) {
+	now := time.Now()
+	spends := asker.spends()
+	if len(spends) == 0 {
+		return spends, nil
+	}
+
+	res, err := kv.HGetAllOf(f.Map(spends, func(s Spend) string { return usageKey(s.Scope, s.ID, s.Period, now) })...).Await()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range spends {
+		if i >= len(res) {
+			break
+		}
+		spends[i].Usage = Usage{
+			Prompt:     f.ParseInt(res[i].Result["prompt"]),
+			Completion: f.ParseInt(res[i].Result["completion"]),
+		}
+	}
+	return spends, nil
+}
+
+// overQuota gives back the first spend of the asker over its quota, if any, where it is never over
+// when the usage can't be looked up
+func overQuota(asker Asker) (Spend, bool) {
+	if !f.Some(asker.spends(), func(s Spend) bool { return s.Quota > 0 }) {
+		return Spend{}, false
+	}
+
+	spends, err := Spending(asker)
+	if err != nil {
+		log.Printf("Failed to look up the AI usage of %s in %s: %s\n", asker.UserID, asker.Channel, err.Error())
+		return Spend{}, false
+	}
+	return f.First(spends, Spend.Over)
+}
+
+// record adds the tokens spent to the user's and the channel's usage of today and this month
+func record(asker Asker, usage Usage) {
+	now := time.Now()
+	spends := asker.spends()
+	if usage.Total() == 0 || len(spends) == 0 {
+		return
+	}
+
+	commands := []kv.KVCommand{}
+	for _, s := range spends {
+		key := usageKey(s.Scope, s.ID, s.Period, now)
+		commands = append(commands,
+			kv.KVCommand{Name: "HINCRBY", Args: []any{key, "prompt", usage.Prompt}},
+			kv.KVCommand{Name: "HINCRBY", Args: []any{key, "completion", usage.Completion}},
+			kv.KVCommand{Name: "EXPIRE", Args: []any{key, int(s.Period.retention().Seconds())}},
+		)
+	}
+
+	// Not waited on by the answer, where the KV store is flushed before shutting down
+	task := kv.Pipeline[int](commands...)
+	go func() {
+		if _, err := task.Await(); err != nil {
+			log.Printf("Failed to record the AI usage of %s in %s: %s\n", asker.UserID, asker.Channel, err.Error())
+		}
+	}()
+}
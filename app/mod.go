@@ -2,29 +2,41 @@ package app
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"time"
 
 	"d-exclaimation.me/relax/app/ai"
 	"d-exclaimation.me/relax/app/emoji"
 	"d-exclaimation.me/relax/app/memes"
 	"d-exclaimation.me/relax/app/mr"
 	"d-exclaimation.me/relax/app/quote"
+	"d-exclaimation.me/relax/config"
+	"d-exclaimation.me/relax/lib/async"
 	"d-exclaimation.me/relax/lib/f"
 	"d-exclaimation.me/relax/lib/kv"
 	"d-exclaimation.me/relax/lib/rpc"
+	"d-exclaimation.me/relax/lib/source"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
-	"github.com/slack-go/slack/socketmode"
 )
 
 type AppContext struct {
+	// Context is only cancelled when the bot has to stop before the resolver is done
+	Context  context.Context
 	Client   *slack.Client
 	AI       *ai.LLM
 	ReplyTo  string
 	UserID   string
 	Channel  string
 	ThreadTS string
+
+	// MessageTS is the timestamp of the message being answered, if any
+	MessageTS string
+
+	// Bot is who the bot itself is
+	Bot Bot
 }
 
 // Define available workflow steps using the common rpc interface
@@ -115,23 +127,29 @@ func workflows(client *slack.Client) rpc.WorkflowsRouter[AppContext] {
 					},
 				}
 			}),
-	)
+	).
+		// Shared behaviour for every workflow step
+		Use(
+			rpc.Recover[AppContext](),
+			rpc.Timing[AppContext](),
+		)
 }
 
 // Define the actions for the mention / commands using the common rpc interface
 func actions(client *slack.Client) rpc.ActionsRouter[AppContext] {
 	return rpc.Actions[AppContext](
 		// @relax hello | Say hello
-		rpc.Contains("hello", func(event string, ctx AppContext) error {
+		rpc.Prefix("hello", func(args rpc.Args, ctx AppContext) error {
 			_, _, err := ctx.Client.PostMessage(
 				ctx.ReplyTo,
 				slack.MsgOptionText("Hello!", false),
 			)
 			return err
-		}),
+		}).
+			Describe("Say hello"),
 
 		// @relax vibecheck | Vibe check
-		rpc.Exact("vibecheck", func(event string, ctx AppContext) error {
+		rpc.Exact("vibecheck", func(args rpc.Args, ctx AppContext) error {
 			_, _, err := ctx.Client.PostMessage(
 				ctx.ReplyTo,
 				slack.MsgOptionBlocks(
@@ -147,41 +165,45 @@ func actions(client *slack.Client) rpc.ActionsRouter[AppContext] {
 				),
 			)
 			return err
-		}),
+		}).
+			Describe("Vibe check"),
+
+		// @relax reviewer ... | Pick random reviewer(s) or see your review statistics
+		rpc.Mount("reviewer", reviewerActions()).
+			Describe("Pick random reviewer(s) from the team, or see your review statistics"),
 
 		// @relax stats | Get the status and statistics of your reviews
-		rpc.Exact("stats", func(event string, ctx AppContext) error {
-			msg, err := mr.SelfReviewerStatus(ctx.Client, ctx.UserID)
-			if err != nil {
-				return err
-			}
-			_, _, err = ctx.Client.PostMessage(
-				ctx.ReplyTo,
-				msg,
-			)
-			return err
-		}),
-
-		// @relax reviewer | Pick a random reviewer and send a dedicated message
-		rpc.Exact("reviewer", func(event string, ctx AppContext) error {
-			msg, err := mr.RandomReviewerWithMessage(
-				ctx.Client,
-				func(u slack.User) bool {
-					return u.IsBot || u.IsRestricted || u.ID == ctx.UserID
-				},
-			)
-			if err != nil {
-				return err
-			}
-			_, _, err = ctx.Client.PostMessage(
-				ctx.ReplyTo,
-				msg,
-			)
-			return err
-		}),
+		rpc.Exact("stats", reviewerStats).
+			Describe("Shortcut for `reviewer stats`"),
+
+		// @relax ai ... | Talk to the AI or manage the conversation
+		rpc.Mount("ai", aiActions()).
+			Describe("Talk to the AI, or manage your conversation with it"),
+
+		// @relax ask [--model <model>] [--precise | --creative] <message> | Talk to the AI with a specific model or style
+		rpc.Exact("ask", askWith).
+			Params(
+				rpc.Arg("message", rpc.String).Required().Rest(),
+				rpc.Flag("model", rpc.String),
+				rpc.Flag("precise", rpc.Bool),
+				rpc.Flag("creative", rpc.Bool),
+			).
+			Describe("Talk to the AI with a specific model, or a more precise / creative style"),
+
+		// @relax persona ... | See, pick, or define the personas of the AI
+		rpc.Mount("persona", personaActions()).
+			Describe("See, pick, or define the personas the AI takes"),
+
+		// @relax usage | See the AI tokens you and the channel spent
+		rpc.Exact("usage", usage).
+			Describe("See the AI tokens you and this channel spent today and this month"),
+
+		// @relax models | List the AI models that can be picked
+		rpc.Exact("models", models).
+			Describe("List the AI models that can be picked with `ask --model`"),
 
 		// @relax quote | Get a random quote and send a dedicated message
-		rpc.Exact("quote", func(event string, ctx AppContext) error {
+		rpc.Exact("quote", func(args rpc.Args, ctx AppContext) error {
 			quote, err := quote.Random().Await()
 			if err != nil {
 				return err
@@ -206,10 +228,12 @@ func actions(client *slack.Client) rpc.ActionsRouter[AppContext] {
 				),
 			)
 			return err
-		}),
+		}).
+			Alias("quotes").
+			Describe("Get a random quote to inspire you"),
 
 		// @relax meme | Get a random meme and send a dedicated message
-		rpc.Exact("meme", func(event string, ctx AppContext) error {
+		rpc.Exact("meme", func(args rpc.Args, ctx AppContext) error {
 			meme, err := memes.Random().Await()
 			if err != nil {
 				return err
@@ -254,113 +278,519 @@ func actions(client *slack.Client) rpc.ActionsRouter[AppContext] {
 			)
 
 			return err
-		}),
+		}).
+			Alias("memes").
+			Describe("Get a random meme from reddit"),
 	).
+		// `/relax <command>` routes the same way as `@relax <command>`
+		Root(config.Env.OAuthAppName()).
+		// Errors and help messages are only shown to the user who asked for them
+		Respond(whisper).
+		// Shared behaviour for every action, including the mounted ones
+		Use(
+			rpc.Recover[AppContext](),
+			rpc.Timing[AppContext](),
+			rpc.RateLimit(10, time.Minute, func(args rpc.Args, ctx AppContext) string {
+				return ctx.UserID
+			}),
+		).
 		// @relax | Default action (AI conversation)
-		Else(func(event string, ctx AppContext) error {
-			_, timestamp, err := ctx.Client.PostMessage(
-				ctx.ReplyTo,
-				f.IfElse(
-					ctx.ThreadTS != "",
-					[]slack.MsgOption{
-						slack.MsgOptionText(emoji.THINK_THONK+emoji.THINK_THONK+emoji.THINK_THONK, false),
-						slack.MsgOptionTS(ctx.ThreadTS),
-					},
-					[]slack.MsgOption{
-						slack.MsgOptionText(emoji.THINK_THONK+emoji.THINK_THONK+emoji.THINK_THONK, false),
-					},
-				)...,
-			)
+		Else(chat)
+}
 
-			if err != nil {
+// Define the handlers for the interactive components of the bot's own messages using the common rpc interface
+func interactions(client *slack.Client, action *rpc.ActionsRouter[AppContext]) rpc.InteractionsRouter[AppContext] {
+	return rpc.Interactions[AppContext](
+		append(
+			action.Interactions(),
+
+			// Reroll button on a chosen reviewer
+			rpc.OnAction(mr.REROLL_ACTION, func(e rpc.Interaction, ctx AppContext) error {
+				choice := mr.ParseChoice(e.Value())
+				if !choice.Decides(ctx.UserID) {
+					return rpc.UserErrorf("Only <@%s> or <@%s> can reroll this reviewer", choice.Requester, choice.Reviewer)
+				}
+
+				blocks := e.Callback.Message.Blocks.BlockSet
+				replacement, err := mr.RerollReviewer(ctx.Client, ctx.Channel, e.Callback.Container.MessageTs, blocks, choice)
+				if errors.Is(err, mr.ErrAlreadyRerolled) {
+					return rpc.UserErrorf("<@%s> was already rerolled", choice.Reviewer)
+				}
+				if errors.Is(err, mr.ErrNoReviewers) {
+					return rpc.UserErrorf("There is no one else available to review right now %s", emoji.DYING_INSIDE)
+				}
+				if err != nil {
+					return err
+				}
+				_, _, _, err = ctx.Client.UpdateMessage(
+					ctx.Channel,
+					e.Callback.Container.MessageTs,
+					slack.MsgOptionBlocks(mr.ReplaceReviewerBlocks(blocks, choice.Reviewer, replacement...)...),
+				)
 				return err
-			}
+			}),
 
-			stream, err := ctx.AI.StreamChat(ctx.UserID, event)
+			// Accept button on a chosen reviewer
+			rpc.OnAction(mr.ACCEPT_ACTION, func(e rpc.Interaction, ctx AppContext) error {
+				choice := mr.ParseChoice(e.Value())
+				if !choice.Decides(ctx.UserID) {
+					return rpc.UserErrorf("Only <@%s> or <@%s> can accept this reviewer", choice.Requester, choice.Reviewer)
+				}
 
-			if err != nil {
+				_, _, _, err := ctx.Client.UpdateMessage(
+					ctx.Channel,
+					e.Callback.Container.MessageTs,
+					slack.MsgOptionBlocks(mr.ReplaceReviewerActionsBlock(
+						e.Callback.Message.Blocks.BlockSet,
+						choice.Reviewer,
+						mr.AcceptedReviewerBlock(choice.Reviewer, ctx.UserID),
+					)...),
+				)
 				return err
-			}
+			}),
 
-			for answer := range stream {
-				_, timestamp, _, err = ctx.Client.UpdateMessage(
-					ctx.ReplyTo,
-					timestamp,
-					f.IfElse(
-						ctx.ThreadTS != "",
-						[]slack.MsgOption{
-							slack.MsgOptionText(
-								fmt.Sprintf("<@%s> %s", ctx.UserID, answer),
-								false,
-							),
-							slack.MsgOptionTS(ctx.ThreadTS),
-						},
-						[]slack.MsgOption{
-							slack.MsgOptionText(
-								fmt.Sprintf("<@%s> %s", ctx.UserID, answer),
-								false,
-							),
-						},
-					)...,
+			// Pick button on a reviewer suggested by the AI, which picks a reviewer for real
+			rpc.OnAction(mr.PICK_ACTION, func(e rpc.Interaction, ctx AppContext) error {
+				if e.Value() != ctx.UserID {
+					return rpc.UserErrorf("Only <@%s> can pick this reviewer", e.Value())
+				}
+
+				msg, err := mr.RandomReviewersWithMessage(ctx.Client, ctx.UserID, 1, []string{ctx.UserID})
+				if errors.Is(err, mr.ErrNoReviewers) {
+					return rpc.UserErrorf("There is no one available to review right now %s", emoji.DYING_INSIDE)
+				}
+				if err != nil {
+					return err
+				}
+
+				// The suggestion is no longer needed, and is not picked twice
+				ctx.Client.PostMessage(ctx.Channel, slack.MsgOptionDeleteOriginal(e.Callback.ResponseURL))
+
+				_, _, err = ctx.Client.PostMessage(ctx.Channel, msg, slack.MsgOptionTS(ctx.ThreadTS))
+				return err
+			}),
+
+			// "Pick reviewer for this MR" message shortcut on a message with a merge request link
+			rpc.OnMessageShortcut(mr.PICK_REVIEWER_SHORTCUT, func(e rpc.Interaction, ctx AppContext) error {
+				message := e.Callback.Message
+				if _, ok := mr.MergeRequestLink(message.Text); !ok {
+					return rpc.UserErrorf("That message doesn't have a link to a merge request %s", emoji.THINK_THONK)
+				}
+
+				msg, err := mr.RandomReviewersWithMessage(
+					ctx.Client,
+					ctx.UserID,
+					1,
+					f.Filter([]string{message.User, ctx.UserID}, func(u string) bool { return u != "" }),
+				)
+				if errors.Is(err, mr.ErrNoReviewers) {
+					return rpc.UserErrorf("There is no one available to review right now %s", emoji.DYING_INSIDE)
+				}
+				if err != nil {
+					return err
+				}
+
+				// Reply in the thread of the merge request message
+				_, _, err = ctx.Client.PostMessage(
+					ctx.Channel,
+					msg,
+					slack.MsgOptionTS(f.IfElse(message.ThreadTimestamp != "", message.ThreadTimestamp, message.Timestamp)),
 				)
+				return err
+			}),
+
+			// "Ask relax" global shortcut, which asks the question through a modal
+			rpc.OnShortcut(ai.ASK_SHORTCUT, func(e rpc.Interaction, ctx AppContext) error {
+				_, err := ctx.Client.OpenView(e.Callback.TriggerID, ai.AskModal())
+				return err
+			}),
+
+			// Question submitted from the "Ask relax" modal, answered in the user's DM
+			rpc.OnSubmit(ai.ASK_MODAL, func(e rpc.Interaction, ctx AppContext) error {
+				ctx.ReplyTo = ctx.UserID
+				return ask(e.Values().Text(ai.QUESTION_INPUT, ai.QUESTION_ACTION), ai.Defaults(), ctx)
+			}),
+
+			// "Stop generating" button on an answer still being generated
+			rpc.OnAction(ai.STOP_ACTION, func(e rpc.Interaction, ctx AppContext) error {
+				err := ctx.AI.Stop(e.Value(), ctx.UserID)
+				if errors.Is(err, ai.ErrNotAsker) {
+					return rpc.UserErrorf("Only the one who asked can stop the answer %s", emoji.SHAME)
+				}
+
+				// The answer may have finished right before the button was clicked
+				if errors.Is(err, ai.ErrNotGenerating) {
+					return nil
+				}
+				return err
+			}),
+		)...,
+	).
+		// Errors are only shown to the user who clicked
+		Respond(whisper).
+		Use(
+			rpc.Recover[AppContext](),
+			rpc.Timing[AppContext](),
+		)
+}
+
+// Define the reviewer namespace (`@relax reviewer ...`) using the common rpc interface
+func reviewerActions() rpc.ActionsRouter[AppContext] {
+	return rpc.Actions[AppContext](
+		// @relax reviewer stats | Get the status and statistics of your reviews
+		rpc.Exact("stats", reviewerStats).
+			Describe("Get the status and statistics of your reviews"),
+	).
+		// @relax reviewer [--exclude @user]... [--count <number>] | Pick random reviewer(s) and send a dedicated message
+		Else(
+			pickReviewers,
+			rpc.Flag("exclude", rpc.User).Variadic(),
+			rpc.Flag("count", rpc.Int).Default("1"),
+		)
+}
+
+// Define the AI namespace (`@relax ai ...`) using the common rpc interface
+func aiActions() rpc.ActionsRouter[AppContext] {
+	return rpc.Actions[AppContext](
+		// @relax ai reset | Forget the conversation so far
+		rpc.Exact("reset", func(args rpc.Args, ctx AppContext) error {
+			// A top level message in a channel would start a new thread, so it has no conversation to forget
+			if ctx.ThreadTS == "" && !direct(ctx) {
+				return rpc.UserErrorf("Every thread here is its own conversation, use `ai reset` in the thread of the one to forget")
 			}
+			if !ctx.AI.Has(conversation(ctx)) {
+				return rpc.UserErrorf("There is no conversation here for me to forget")
+			}
+
+			ctx.AI.ClearHistory(conversation(ctx))
+			_, err := ctx.Client.PostEphemeral(
+				ctx.ReplyTo,
+				ctx.UserID,
+				slack.MsgOptionText(fmt.Sprintf("%s I have forgotten our conversation", emoji.DONE), false),
+			)
 			return err
-		})
+		}).
+			Alias("clear").
+			Describe("Forget the conversation so far and start fresh"),
+	).
+		// @relax ai <message> | Talk to the AI
+		Else(chat)
+}
+
+// Define the persona namespace (`@relax persona ...`) using the common rpc interface
+func personaActions() rpc.ActionsRouter[AppContext] {
+	return rpc.Actions[AppContext](
+		// @relax persona list | List the personas the AI can take
+		rpc.Exact("list", listPersonas).
+			Describe("List the personas I can take"),
+
+		// @relax persona set <name> [--channel] | Pick the persona for your conversations, or the channel's as an admin
+		rpc.Exact("set", rpc.Authorize[AppContext](channelAdmin)(setPersona)).
+			Params(
+				rpc.Arg("name", rpc.String).Required(),
+				rpc.Flag("channel", rpc.Bool),
+			).
+			Describe("Pick the persona for your conversations, or for this channel with `--channel` (admins only)"),
+
+		// @relax persona unset [--channel] | Go back to the default persona (admins only for the channel)
+		rpc.Exact("unset", rpc.Authorize[AppContext](channelAdmin)(unsetPersona)).
+			Params(
+				rpc.Flag("channel", rpc.Bool),
+			).
+			Describe("Go back to the default persona for your conversations, or for this channel with `--channel` (admins only)"),
+
+		// @relax persona define <name> <prompt> [--model <model>] [--temperature <number>] [--tools <tool,...|none>] | Create or replace a persona (admins only)
+		rpc.Exact("define", rpc.Authorize[AppContext](admin)(definePersona)).
+			Params(
+				rpc.Arg("name", rpc.String).Required(),
+				rpc.Arg("prompt", rpc.String).Required().Variadic(),
+				rpc.Flag("model", rpc.String),
+				rpc.Flag("temperature", rpc.String),
+				rpc.Flag("tools", rpc.String),
+			).
+			Describe("Create or replace a persona with its prompt, and optionally its model, temperature, and tools (admins only)"),
+
+		// @relax persona delete <name> | Delete a persona (admins only)
+		rpc.Exact("delete", rpc.Authorize[AppContext](admin)(deletePersona)).
+			Params(
+				rpc.Arg("name", rpc.String).Required(),
+			).
+			Alias("remove").
+			Describe("Delete a persona, where the ones who picked it go back to the default (admins only)"),
+	).
+		// @relax persona | Show the persona the AI takes for you here
+		Else(showPersona)
+}
+
+// whisper posts a message only visible to the user, in the same channel / thread they are in,
+// or in their DM if they are not in one (e.g. global shortcuts and modals)
+func whisper(ctx AppContext, msg ...slack.MsgOption) error {
+	if ctx.Channel == "" {
+		_, _, err := ctx.Client.PostMessage(ctx.UserID, msg...)
+		return err
+	}
+	_, err := ctx.Client.PostEphemeral(
+		ctx.ReplyTo,
+		ctx.UserID,
+		f.IfElse(
+			ctx.ThreadTS != "",
+			append(msg, slack.MsgOptionTS(ctx.ThreadTS)),
+			msg,
+		)...,
+	)
+	return err
+}
+
+// reviewerStats sends the status and statistics of the user's reviews
+func reviewerStats(args rpc.Args, ctx AppContext) error {
+	msg, err := mr.SelfReviewerStatus(ctx.Client, ctx.UserID)
+	if err != nil {
+		return err
+	}
+	_, _, err = ctx.Client.PostMessage(
+		ctx.ReplyTo,
+		msg,
+	)
+	return err
+}
+
+// maxReviewers is the most reviewers picked at once, keeping the message well within Slack's limit of blocks
+const maxReviewers = 5
+
+// pickReviewers picks random reviewer(s), leaving out the user and anyone excluded
+func pickReviewers(args rpc.Args, ctx AppContext) error {
+	if count := args.Int("count"); count < 1 || count > maxReviewers {
+		return rpc.UserErrorf("I can only pick between 1 and %d reviewers at once, not %d", maxReviewers, count)
+	}
+
+	msg, err := mr.RandomReviewersWithMessage(
+		ctx.Client,
+		ctx.UserID,
+		args.Int("count"),
+		append(args.Strings("exclude"), ctx.UserID),
+	)
+	if errors.Is(err, mr.ErrNoReviewers) {
+		return rpc.UserErrorf("There is no one available to review right now %s", emoji.DYING_INSIDE)
+	}
+	if err != nil {
+		return err
+	}
+	_, _, err = ctx.Client.PostMessage(
+		ctx.ReplyTo,
+		msg,
+	)
+	return err
+}
+
+// chat streams the AI answer to the message as the bot's reply
+func chat(args rpc.Args, ctx AppContext) error {
+	if args.Text() == "" {
+		return &rpc.UsageError{
+			Command: "ai",
+			Reason:  "Ask me something first",
+			Usage:   "ai <message>",
+		}
+	}
+	return ask(args.Text(), ai.Defaults(), ctx)
+}
+
+// askWith streams the AI answer to the message as the bot's reply, with the model and style picked by the user
+func askWith(args rpc.Args, ctx AppContext) error {
+	options := ai.Defaults()
+	if args.Has("model") {
+		if !ai.Allowed(args.String("model")) {
+			return rpc.UserErrorf("`%s` is not one of the models I can use, see `models` for the ones I can", args.String("model"))
+		}
+		options = options.WithModel(args.String("model"))
+	}
+	if args.Bool("precise") && args.Bool("creative") {
+		return rpc.UserErrorf("I can only be either precise or creative, not both")
+	}
+	if args.Bool("precise") {
+		options = options.Precise()
+	}
+	if args.Bool("creative") {
+		options = options.Creative()
+	}
+	return ask(args.String("message"), options, ctx)
 }
 
-// Listen for events using Slack's Socket Mode (WebSocket / Realtime connecion)
-// https://api.slack.com/apis/connections/socket
-// SocketMode usually provides faster response times than the Web Events API,
-// and it doesn't require a public endpoint.
-func Listen(client *slack.Client, ai *ai.LLM) {
-	// Create a new socket mode connection
-	conn := socketmode.New(client)
+// models lists the AI models allowed to be picked
+func models(args rpc.Args, ctx AppContext) error {
+	_, _, err := ctx.Client.PostMessage(
+		ctx.ReplyTo,
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(
+					slack.MarkdownType,
+					f.Text(
+						append(
+							[]string{fmt.Sprintf("%s *Models I can use*", emoji.BIG_BRAIN)},
+							f.Map(ai.Models(), func(model string) string {
+								return fmt.Sprintf(
+									"> • `%s`%s",
+									model,
+									f.IfElse(model == ai.Defaults().Model, " _(default)_", ""),
+								)
+							})...,
+						)...,
+					),
+					false,
+					false,
+				),
+				nil,
+				nil,
+			),
+			slack.NewContextBlock(
+				"",
+				slack.NewTextBlockObject(
+					slack.MarkdownType,
+					"Pick one with `ask --model <model> <message>`",
+					false,
+					false,
+				),
+			),
+		),
+	)
+	return err
+}
 
+// ask streams the AI answer to the question as the bot's reply, which the user can stop while it is generating
+func ask(question string, options ai.Options, ctx AppContext) error {
+	// The answer is stopped by the "Stop generating" button on the reply, or when the bot has to stop
+	id := fmt.Sprintf("%s:%d", ctx.UserID, time.Now().UnixNano())
+	generating, done := ctx.AI.Generate(ctx.Context, id, ctx.UserID)
+	defer done()
+
+	// A top level message in a channel is answered in its own thread, which is where the conversation carries on
+	if ctx.ThreadTS == "" && !direct(ctx) {
+		ctx.ThreadTS = ctx.MessageTS
+	}
+
+	writer := newStreamWriter(ctx.Client, ctx.ReplyTo, ctx.ThreadTS, fmt.Sprintf("<@%s> ", ctx.UserID), id)
+	if err := writer.Start(emoji.THINK_THONK + emoji.THINK_THONK + emoji.THINK_THONK); err != nil {
+		return err
+	}
+
+	// Without a message to reply to (e.g. slash commands), the answer itself starts the thread
+	if ctx.ThreadTS == "" && !direct(ctx) {
+		ctx.ThreadTS = writer.TS()
+	}
+
+	// The replies in the thread carry on the conversation without a mention
+	if !direct(ctx) {
+		rememberThread(ctx.Channel, ctx.ThreadTS)
+	}
+
+	stream := ctx.AI.StreamChat(
+		generating,
+		conversation(ctx),
+		ai.Asker{UserID: ctx.UserID, Channel: ctx.Channel},
+		question,
+		history(ctx),
+		options.WithTools(tools(ctx)...),
+	)
+
+	// answered is the answer so far with a notice of why it is not done
+	answered := func(answer string, notice string) string {
+		if answer == "" {
+			return notice
+		}
+		return f.Text(answer, "", notice)
+	}
+
+	// The reply is closed even if the answer ends without saying how, so it never stays generating
+	latest := ""
+	defer func() {
+		writer.Close(answered(latest, fmt.Sprintf("_Something went wrong while answering, ask me again_ %s", emoji.DYING_INSIDE)))
+	}()
+
+	for event := range stream {
+		switch e := event.(type) {
+		case ai.Delta:
+			latest = e.Answer
+			writer.Write(e.Answer)
+
+		case ai.Done:
+			return writer.Close(e.Answer)
+
+		case ai.OverQuota:
+			return writer.Close(overQuota(e.Spend))
+
+		case ai.RateLimited:
+			return writer.Close(answered(e.Answer, fmt.Sprintf("_I am being asked too much right now, ask me again in a bit_ %s", emoji.OVERWORK)))
+
+		case ai.Failed:
+			switch {
+			// The bot is shutting down before the answer is done
+			case ctx.Context.Err() != nil:
+				return writer.Close(answered(e.Answer, fmt.Sprintf("_I am restarting, ask me again in a bit_ %s", emoji.BRB)))
+
+			case errors.Is(e.Err, context.Canceled):
+				return writer.Close(answered(e.Answer, "_Stopped generating_"))
+
+			default:
+				return writer.Close(answered(e.Answer, fmt.Sprintf("_Something went wrong while answering, ask me again_ %s", emoji.DYING_INSIDE)))
+			}
+		}
+	}
+	return nil
+}
+
+const (
+	// shutdownTimeout is how long the tasks in flight have to finish after the bot stops accepting events
+	shutdownTimeout = 10 * time.Second
+
+	// haltTimeout is how long the tasks still in flight after the shutdown timeout have to wrap up
+	haltTimeout = 3 * time.Second
+)
+
+// Listen for events from the source (e.g. Socket Mode or HTTP Events API) and handle them,
+// until the context is cancelled (e.g. SIGTERM) or the source fails, then waits for the tasks in flight to finish
+func Listen(ctx context.Context, client *slack.Client, ai *ai.LLM, src source.Source) error {
 	// Create action handler
 	action := actions(client)
 
 	// Create workflow handler
 	workflow := workflows(client)
 
-	// Context for graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
+	// Create interactive components handler
+	interaction := interactions(client, &action)
+
+	// Who the bot is, to tell apart its own messages and mentions
+	auth, err := client.AuthTest()
+	if err != nil {
+		return err
+	}
+	bot := Bot{UserID: auth.UserID, BotID: auth.BotID}
 
-	// Graceful shutdown
+	// Threads holding an AI conversation, where replies are answered without a mention
+	started := newThreads()
+
+	// Tasks still in flight, to wait for before shutting down
+	var inflight async.Group
+
+	// Context for the tasks that are still not done after the deadline to stop (e.g. let the user know)
+	halt, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	// Stop accepting events once the source stops, even if it stops on its own
+	listening, cancel := context.WithCancel(ctx)
 	defer cancel()
+	stopped := make(chan struct{})
 
 	// Start listening on a separate goroutine
 	go func() {
+		defer close(stopped)
 		for {
 			select {
-			case <-ctx.Done():
+			case <-listening.Done():
 				return
-			case e1 := <-conn.Events:
-				switch e1.Type {
-
-				// Connecting to Slack
-				case socketmode.EventTypeConnecting:
-					log.Println("Connecting to Slack with Socket Mode...")
-
-				// Connection error
-				case socketmode.EventTypeConnectionError:
-					log.Println("Connection failed. Retrying later...")
-
-				// Connected to Slack
-				case socketmode.EventTypeConnected:
-					log.Println("Connected to Slack with Socket Mode.")
+			case e1 := <-src.Events():
+				switch e2 := e1.Data.(type) {
 
 				// Events from Slack
-				case socketmode.EventTypeEventsAPI:
-
-					// Casting event
-					e2, ok := e1.Data.(slackevents.EventsAPIEvent)
-					if !ok {
-						continue
-					}
-
-					// Make sure Slack knows we acknowledge the event
-					conn.Ack(*e1.Request)
-
+				case slackevents.EventsAPIEvent:
 					// Handle the event itself
 					switch e2.Type {
 
@@ -373,75 +803,122 @@ func Listen(client *slack.Client, ai *ai.LLM) {
 						// Handling app mentions (1st way of interacting with the bot)
 						case *slackevents.AppMentionEvent:
 							log.Printf("Receiving mentions \"%s\" from %s\n", event.Text, event.User)
-							action.HandleMentionAsync(event.Text, func() AppContext {
-								return AppContext{
-									Client:   client,
-									AI:       ai,
-									ReplyTo:  event.Channel,
-									ThreadTS: event.ThreadTimeStamp,
-									Channel:  event.Channel,
-									UserID:   event.User,
+							inflight.Go(func() {
+								action.HandleMentionAsync(event.Text, func() AppContext {
+									return AppContext{
+										Context:   halt,
+										Bot:       bot,
+										Client:    client,
+										AI:        ai,
+										ReplyTo:   event.Channel,
+										ThreadTS:  event.ThreadTimeStamp,
+										MessageTS: event.TimeStamp,
+										Channel:   event.Channel,
+										UserID:    event.User,
+									}
+								}).Await()
+							})
+
+						// Handling direct messages and replies in the bot's threads, as if the bot was mentioned
+						case *slackevents.MessageEvent:
+							if !bot.Conversational(event) {
+								continue
+							}
+							if event.ChannelType != slack.TYPE_IM && event.ThreadTimeStamp == "" {
+								continue
+							}
+
+							inflight.Go(func() {
+								if event.ChannelType != slack.TYPE_IM && !started.Conversational(event.Channel, event.ThreadTimeStamp) {
+									return
 								}
+
+								log.Printf("Receiving message \"%s\" from %s\n", event.Text, event.User)
+								action.HandleMentionAsync(event.Text, func() AppContext {
+									return AppContext{
+										Context:   halt,
+										Bot:       bot,
+										Client:    client,
+										AI:        ai,
+										ReplyTo:   event.Channel,
+										ThreadTS:  event.ThreadTimeStamp,
+										MessageTS: event.TimeStamp,
+										Channel:   event.Channel,
+										UserID:    event.User,
+									}
+								}).Await()
 							})
 
 						case *slackevents.WorkflowStepExecuteEvent:
 							log.Printf("Receiving workflow step execute event from %s\n", event.CallbackID)
 
-							workflow.HandleAsync(event, func() AppContext {
-								return AppContext{
-									Client:  client,
-									AI:      ai,
-									ReplyTo: event.WorkflowStep.WorkflowStepExecuteID,
-								}
+							inflight.Go(func() {
+								workflow.HandleAsync(event, func() AppContext {
+									return AppContext{
+										Context: halt,
+										Bot:     bot,
+										Client:  client,
+										AI:      ai,
+										ReplyTo: event.WorkflowStep.WorkflowStepExecuteID,
+									}
+								}).Await()
 							})
 						}
 
 					}
 
 				// Interactive components from Slack
-				case socketmode.EventTypeInteractive:
-					e2, ok := e1.Data.(slack.InteractionCallback)
-					if !ok {
-						continue
-					}
-
-					// Make sure Slack knows we acknowledge the event
-					conn.Ack(*e1.Request)
-
+				case slack.InteractionCallback:
 					log.Printf("Receiving interaction callback from %s\n", e2.CallbackID)
 
 					// Handle the workflow related event (3rd way of interacting with the bot)
-					workflow.HandleInteractionAsync(e2, func() AppContext {
-						return AppContext{
-							Client:  client,
-							AI:      ai,
-							ReplyTo: e2.WorkflowStep.WorkflowID,
-							UserID:  e2.User.ID,
-							Channel: e2.Channel.ID,
-						}
+					inflight.Go(func() {
+						workflow.HandleInteractionAsync(e2, func() AppContext {
+							return AppContext{
+								Context: halt,
+								Bot:     bot,
+								Client:  client,
+								AI:      ai,
+								ReplyTo: e2.WorkflowStep.WorkflowID,
+								UserID:  e2.User.ID,
+								Channel: e2.Channel.ID,
+							}
+						}).Await()
 					})
 
-				// Slash commands from Slack
-				case socketmode.EventTypeSlashCommand:
-					command, ok := e1.Data.(slack.SlashCommand)
-					if !ok {
-						continue
-					}
-
-					// Make sure Slack knows we acknowledge the event
-					conn.Ack(*e1.Request)
+					// Handle the interactive components of the bot's own messages (e.g. buttons) and the shortcuts
+					inflight.Go(func() {
+						interaction.HandleAsync(e2, func() AppContext {
+							return AppContext{
+								Context:  halt,
+								Bot:      bot,
+								Client:   client,
+								AI:       ai,
+								ReplyTo:  e2.Channel.ID,
+								ThreadTS: e2.Container.ThreadTs,
+								UserID:   e2.User.ID,
+								Channel:  e2.Channel.ID,
+							}
+						}).Await()
+					})
 
+				// Slash commands from Slack
+				case slack.SlashCommand:
 					// Handle the event itself (2nd way of interacting with the bot)
-					log.Printf("Receiving slash commands %s from %s <@%s>\n", command.Command, command.UserName, command.UserID)
-
-					action.HandleCommandAsync(command.Command, func() AppContext {
-						return AppContext{
-							Client:  client,
-							AI:      ai,
-							ReplyTo: command.ChannelID,
-							UserID:  command.UserID,
-							Channel: command.ChannelID,
-						}
+					log.Printf("Receiving slash commands %s \"%s\" from %s <@%s>\n", e2.Command, e2.Text, e2.UserName, e2.UserID)
+
+					inflight.Go(func() {
+						action.HandleCommandAsync(e2.Command, e2.Text, func() AppContext {
+							return AppContext{
+								Context: halt,
+								Bot:     bot,
+								Client:  client,
+								AI:      ai,
+								ReplyTo: e2.ChannelID,
+								UserID:  e2.UserID,
+								Channel: e2.ChannelID,
+							}
+						}).Await()
 					})
 				}
 			}
@@ -450,5 +927,22 @@ func Listen(client *slack.Client, ai *ai.LLM) {
 
 	log.Println("Listening to Slack Events...")
 
-	conn.Run()
+	err = src.Run(listening)
+	cancel()
+	<-stopped
+
+	// Stopped accepting events, give the tasks in flight some time to finish
+	log.Println("Waiting for the tasks in flight to finish...")
+	deadline, cancelDeadline := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelDeadline()
+	if inflight.Wait(deadline) != nil {
+		// Let the tasks still going know they have to stop (e.g. to tell the user the bot is restarting)
+		log.Println("Some tasks are still not done, stopping them...")
+		stop()
+		grace, cancelGrace := context.WithTimeout(context.Background(), haltTimeout)
+		defer cancelGrace()
+		inflight.Wait(grace)
+	}
+
+	return err
 }
@@ -2,6 +2,7 @@ package mr
 
 import (
 	"fmt"
+	"strings"
 
 	"d-exclaimation.me/relax/app/emoji"
 	"d-exclaimation.me/relax/lib/f"
@@ -18,6 +19,15 @@ const (
 	CHANNEL_INPUT  = "mr-channel-input"
 
 	RANDOM_REVIEWER = "mr-reviewer-output"
+
+	REROLL_ACTION = "mr-reroll"
+	ACCEPT_ACTION = "mr-accept"
+	PICK_ACTION   = "mr-pick"
+
+	REVIEWER_BLOCK         = "mr-reviewer-"
+	REVIEWER_ACTIONS_BLOCK = "mr-reviewer-actions-"
+
+	PICK_REVIEWER_SHORTCUT = "mr-pick-reviewer"
 )
 
 func ReviewerWorkflowStepBlocks(reviewee string, channel string) []slack.Block {
@@ -223,3 +233,133 @@ func (r *Reviewer) ChosenReviewerBlock() slack.Block {
 		),
 	)
 }
+
+// Choice is what the buttons of a chosen reviewer carry, which is the reviewer, who asked for them, and who is left out
+type Choice struct {
+	Reviewer  string
+	Requester string
+	Excluded  []string
+}
+
+// ParseChoice reads the choice from the value of the buttons of a chosen reviewer
+func ParseChoice(value string) Choice {
+	ids := strings.Split(value, ",")
+	choice := Choice{Reviewer: ids[0]}
+	if len(ids) > 1 {
+		choice.Requester = ids[1]
+		choice.Excluded = ids[2:]
+	}
+	return choice
+}
+
+// Decides returns true if the user can accept or reroll the chosen reviewer, which are the one who asked and the reviewer
+func (c Choice) Decides(user string) bool {
+	return user == c.Requester || user == c.Reviewer
+}
+
+// ChosenReviewerBlocks represents the chosen reviewer with buttons to reroll (still leaving out the excluded users) or accept,
+// which only the requester and the reviewer can click
+func (r *Reviewer) ChosenReviewerBlocks(requester string, excluded []string) []slack.Block {
+	section := r.ChosenReviewerBlock().(*slack.SectionBlock)
+	section.BlockID = REVIEWER_BLOCK + r.User.ID
+
+	value := strings.Join(append([]string{r.User.ID, requester}, excluded...), ",")
+
+	return []slack.Block{
+		section,
+		slack.NewActionBlock(
+			REVIEWER_ACTIONS_BLOCK+r.User.ID,
+			slack.NewButtonBlockElement(
+				ACCEPT_ACTION,
+				value,
+				slack.NewTextBlockObject(slack.PlainTextType, "Accept", false, false),
+			).WithStyle(slack.StylePrimary),
+			slack.NewButtonBlockElement(
+				REROLL_ACTION,
+				value,
+				slack.NewTextBlockObject(slack.PlainTextType, "Reroll", false, false),
+			),
+		),
+	}
+}
+
+// SuggestedReviewerBlocks represents a reviewer only suggested (e.g. by the AI), with a button for the requester
+// to pick a reviewer for real, which is counted as a review
+func SuggestedReviewerBlocks(reviewer string, requester string) []slack.Block {
+	return []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(
+				slack.MarkdownType,
+				fmt.Sprintf("%s <@%s> was only suggested, and is not counted as a review until a reviewer is picked for real", emoji.THINK_THONK, reviewer),
+				false,
+				false,
+			),
+			nil,
+			nil,
+		),
+		slack.NewActionBlock(
+			"",
+			slack.NewButtonBlockElement(
+				PICK_ACTION,
+				requester,
+				slack.NewTextBlockObject(slack.PlainTextType, "Pick a reviewer", false, false),
+			).WithStyle(slack.StylePrimary),
+		),
+	}
+}
+
+// AcceptedReviewerBlock represents the reviewer being accepted, in place of the buttons
+func AcceptedReviewerBlock(reviewer string, by string) slack.Block {
+	return slack.NewContextBlock(
+		REVIEWER_ACTIONS_BLOCK+reviewer,
+		slack.NewTextBlockObject(
+			slack.MarkdownType,
+			fmt.Sprintf("%s Accepted by <@%s>", emoji.DONE, by),
+			false,
+			false,
+		),
+	)
+}
+
+// ReplaceReviewerBlocks replaces the blocks of a chosen reviewer in a message with the replacement blocks
+func ReplaceReviewerBlocks(blocks []slack.Block, reviewer string, replacement ...slack.Block) []slack.Block {
+	res := make([]slack.Block, 0, len(blocks)+len(replacement))
+	replaced := false
+	for _, block := range blocks {
+		id := blockID(block)
+		if id != REVIEWER_BLOCK+reviewer && id != REVIEWER_ACTIONS_BLOCK+reviewer {
+			res = append(res, block)
+			continue
+		}
+		if !replaced {
+			res = append(res, replacement...)
+			replaced = true
+		}
+	}
+	return res
+}
+
+// ShowsReviewer returns true if the reviewer is still one of the chosen reviewers in a message
+func ShowsReviewer(blocks []slack.Block, reviewer string) bool {
+	return f.Some(blocks, func(block slack.Block) bool { return blockID(block) == REVIEWER_BLOCK+reviewer })
+}
+
+// ReplaceReviewerActionsBlock replaces only the buttons of a chosen reviewer in a message with the replacement block
+func ReplaceReviewerActionsBlock(blocks []slack.Block, reviewer string, replacement slack.Block) []slack.Block {
+	return f.Map(blocks, func(block slack.Block) slack.Block {
+		return f.IfElse(blockID(block) == REVIEWER_ACTIONS_BLOCK+reviewer, replacement, block)
+	})
+}
+
+// blockID returns the ID of the blocks used in the reviewer messages
+func blockID(block slack.Block) string {
+	switch b := block.(type) {
+	case *slack.SectionBlock:
+		return b.BlockID
+	case *slack.ActionBlock:
+		return b.BlockID
+	case *slack.ContextBlock:
+		return b.BlockID
+	}
+	return ""
+}
@@ -0,0 +1,12 @@
+package mr
+
+import "regexp"
+
+// mergeRequestLink matches a link to a GitLab merge request, in Slack's `<url|label>` format or as is
+var mergeRequestLink = regexp.MustCompile(`https?://[^\s|>]+/merge_requests/\d+`)
+
+// MergeRequestLink finds the first link to a GitLab merge request in the message
+func MergeRequestLink(text string) (string, bool) {
+	link := mergeRequestLink.FindString(text)
+	return link, link != ""
+}
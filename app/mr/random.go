@@ -3,6 +3,7 @@ package mr
 import (
 	"errors"
 	"log"
+	"time"
 
 	"d-exclaimation.me/relax/app/emoji"
 	"d-exclaimation.me/relax/lib/async"
@@ -12,6 +13,15 @@ import (
 	"github.com/slack-go/slack"
 )
 
+// ErrNoReviewers is the error given back when everyone in the team is excluded or unavailable
+var ErrNoReviewers = errors.New("no available reviewers")
+
+// ErrAlreadyRerolled is the error given back when the reviewer in a message was already rerolled (e.g. a double click)
+var ErrAlreadyRerolled = errors.New("reviewer already rerolled")
+
+// rerollTTL is how long a reroll is remembered, where older messages are covered by no longer showing the reviewer
+const rerollTTL = 30 * 24 * time.Hour
+
 func randomlyPickReviewer(reviewers []Reviewer) Reviewer {
 	reviews := f.MaxBy(reviewers, func(reviewer Reviewer) int {
 		return reviewer.ReviewCount
@@ -28,7 +38,7 @@ func randomlyPickReviewer(reviewers []Reviewer) Reviewer {
 	return random.Weighted[Reviewer](values...)
 }
 
-// ReadonlyRandomReviewer picks a random reviewer from the team, excluding the given user
+// ReadonlyRandomReviewer picks a random reviewer from the team, excluding the given user, without counting it as a review
 func ReadonlyRandomReviewer(client *slack.Client, excluding func(slack.User) bool) (Reviewer, error) {
 	teamMembers, err := GetMembers(client, "team").Await()
 
@@ -39,6 +49,10 @@ func ReadonlyRandomReviewer(client *slack.Client, excluding func(slack.User) boo
 	filteredMembers := f.Filter(teamMembers, func(user slack.User) bool {
 		return !excluding(user) && !user.IsBot && user.Profile.StatusEmoji != emoji.BRB
 	})
+	if len(filteredMembers) < 1 {
+		return Reviewer{}, ErrNoReviewers
+	}
+
 	keys := f.Map(filteredMembers, func(member slack.User) string {
 		return "reviews:" + member.ID
 	})
@@ -52,77 +66,137 @@ func ReadonlyRandomReviewer(client *slack.Client, excluding func(slack.User) boo
 	}).Await()
 
 	if err != nil {
-		return Reviewer{}, nil
+		return Reviewer{}, err
 	}
 
+	log.Print("Selecting reviewers: ")
 	reviewers := make([]Reviewer, len(filteredMembers))
 	for i, member := range filteredMembers {
 		reviewers[i] = Reviewer{
 			User:        member,
 			ReviewCount: reviews[i],
 		}
+		log.Printf(" %s (%d)", reviewers[i].User.Name, reviewers[i].ReviewCount)
 	}
-	reviewer := randomlyPickReviewer(reviewers)
-	return reviewer, nil
+	log.Println()
+
+	return randomlyPickReviewer(reviewers), nil
 }
 
-// RandomReviewer picks a random reviewer from the team, excluding the given user
+// RandomReviewer picks a random reviewer from the team, excluding the given user, and counts it as a review
 func RandomReviewer(client *slack.Client, excluding func(slack.User) bool) (Reviewer, error) {
-	teamMembers, err := GetMembers(client, "team").Await()
-
+	reviewer, err := ReadonlyRandomReviewer(client, excluding)
 	if err != nil {
 		return Reviewer{}, err
 	}
 
-	filteredMembers := f.Filter(teamMembers, func(user slack.User) bool {
-		return !excluding(user) && !user.IsBot && user.Profile.StatusEmoji != emoji.BRB
-	})
-	keys := f.Map(filteredMembers, func(member slack.User) string {
-		return "reviews:" + member.ID
-	})
-	reviews, err := async.New(func() ([]int, error) {
-		data, err := kv.GetAll(keys...).Await()
+	kv.Incr("reviews:" + reviewer.User.ID)
+
+	return reviewer, nil
+}
+
+// RandomReviewerWithMessage is a resolver that picks a random reviewer from the team and returns an appropriate message
+func RandomReviewerWithMessage(client *slack.Client, excluding func(slack.User) bool) (slack.MsgOption, error) {
+	reviewer, err := RandomReviewer(client, excluding)
+
+	if err != nil {
+		return nil, err
+	}
+
+	msg := slack.MsgOptionBlocks(
+		reviewer.ChosenReviewerBlock(),
+	)
+
+	return msg, nil
+}
+
+// RandomReviewersWithMessage is a resolver that picks multiple different reviewers from the team, excluding the given users,
+// and returns an appropriate message with buttons for the requester to reroll or accept each of them
+func RandomReviewersWithMessage(client *slack.Client, requester string, count int, excluded []string) (slack.MsgOption, error) {
+	chosen := make([]Reviewer, 0)
+
+	for i := 0; i < count || i == 0; i++ {
+		reviewer, err := RandomReviewer(client, func(u slack.User) bool {
+			return isExcluded(u, excluded) || f.Some(chosen, func(r Reviewer) bool { return r.User.ID == u.ID })
+		})
+
+		// Not enough reviewers for the rest, just stick with what we have
+		if err != nil && i > 0 {
+			break
+		}
 		if err != nil {
 			return nil, err
 		}
 
-		return f.Map(data, func(res kv.KVPacket[string]) int { return f.ParseInt(res.Result) }), nil
-	}).Await()
+		chosen = append(chosen, reviewer)
+	}
+
+	ids := f.Map(chosen, func(r Reviewer) string { return r.User.ID })
+	blocks := make([]slack.Block, 0)
+	for _, reviewer := range chosen {
+		others := f.Filter(ids, func(id string) bool { return id != reviewer.User.ID })
+		blocks = append(blocks, reviewer.ChosenReviewerBlocks(requester, append(others, excluded...))...)
+	}
 
+	return slack.MsgOptionBlocks(blocks...), nil
+}
+
+// RerollReviewer undoes the pick of the chosen reviewer in the message and picks another one, returning the replacement blocks
+// A reviewer is only rerolled once per message, however many times the button is clicked
+func RerollReviewer(client *slack.Client, channel string, ts string, blocks []slack.Block, choice Choice) ([]slack.Block, error) {
+	if !ShowsReviewer(blocks, choice.Reviewer) {
+		return nil, ErrAlreadyRerolled
+	}
+
+	key := "reroll:" + channel + ":" + ts + ":" + choice.Reviewer
+	claimed, err := kv.SetNX(key, choice.Requester, rerollTTL).Await()
 	if err != nil {
-		return Reviewer{}, nil
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrAlreadyRerolled
 	}
 
-	log.Print("Selecting reviewers: ")
-	reviewers := make([]Reviewer, len(filteredMembers))
-	for i, member := range filteredMembers {
-		reviewers[i] = Reviewer{
-			User:        member,
-			ReviewCount: reviews[i],
-		}
-		log.Printf(" %s (%d)", reviewers[i].User.Name, reviewers[i].ReviewCount)
+	excluded := append(choice.Excluded, choice.Reviewer)
+	reviewer, err := RandomReviewer(client, func(u slack.User) bool {
+		return isExcluded(u, excluded)
+	})
+	if err != nil {
+		// Nothing was rerolled, so it can be tried again
+		kv.Del(key)
+		return nil, err
 	}
-	log.Println()
 
-	reviewer := randomlyPickReviewer(reviewers)
-	kv.Incr("reviews:" + reviewer.User.ID)
+	kv.Decr("reviews:" + choice.Reviewer)
 
-	return reviewer, nil
+	return reviewer.ChosenReviewerBlocks(choice.Requester, excluded), nil
 }
 
-// RandomReviewerWithMessage is a resolver that picks a random reviewer from the team and returns an appropriate message
-func RandomReviewerWithMessage(client *slack.Client, excluding func(slack.User) bool) (slack.MsgOption, error) {
-	reviewer, err := RandomReviewer(client, excluding)
+// isExcluded returns true if the user can never be picked as a reviewer
+func isExcluded(u slack.User, excluded []string) bool {
+	return u.IsBot || u.IsRestricted || f.IsMember(excluded, u.ID)
+}
 
+// TeamReviewers gives back everyone in the team along with how many reviews they have done
+func TeamReviewers(client *slack.Client) ([]Reviewer, error) {
+	members, err := GetMembers(client, "team").Await()
 	if err != nil {
 		return nil, err
 	}
 
-	msg := slack.MsgOptionBlocks(
-		reviewer.ChosenReviewerBlock(),
-	)
+	data, err := kv.GetAll(f.Map(members, func(member slack.User) string { return "reviews:" + member.ID })...).Await()
+	if err != nil {
+		return nil, err
+	}
 
-	return msg, nil
+	reviewers := make([]Reviewer, len(members))
+	for i, member := range members {
+		reviewers[i] = Reviewer{
+			User:        member,
+			ReviewCount: f.ParseInt(data[i].Result),
+		}
+	}
+	return reviewers, nil
 }
 
 // SelfReviewerStatus is a resolver that returns the number of reviews a user has done
@@ -0,0 +1,314 @@
+package app
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"d-exclaimation.me/relax/app/ai"
+	"d-exclaimation.me/relax/app/emoji"
+	"d-exclaimation.me/relax/config"
+	"d-exclaimation.me/relax/lib/f"
+	"d-exclaimation.me/relax/lib/mrkdwn"
+	"d-exclaimation.me/relax/lib/rpc"
+	"github.com/slack-go/slack"
+)
+
+// personaName is what a persona can be named, to be easy to type in a command
+var personaName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// admin returns true if the user can change the personas for everyone, which are the ones in `ADMIN_IDS`,
+// or the workspace admins and owners if there are none
+func admin(args rpc.Args, ctx AppContext) bool {
+	admins := config.Env.Admins()
+	if len(admins) > 0 {
+		return f.IsMember(admins, ctx.UserID)
+	}
+	user, err := ctx.Client.GetUserInfo(ctx.UserID)
+	if err != nil {
+		return false
+	}
+	return user.IsAdmin || user.IsOwner
+}
+
+// channelAdmin returns true if the user can run the persona command, where only admins can do it for a whole channel
+func channelAdmin(args rpc.Args, ctx AppContext) bool {
+	return !args.Bool("channel") || admin(args, ctx)
+}
+
+// describe shows the persona, with its prompt and settings
+func describe(persona ai.Persona) string {
+	prompt := []rune(persona.Prompt)
+	if len(prompt) > 280 {
+		prompt = append(prompt[:280], '…')
+	}
+
+	settings := []string{
+		fmt.Sprintf("Model: `%s`", f.IfElse(persona.Model != "", persona.Model, ai.Defaults().Model)),
+	}
+	if persona.Temperature != nil {
+		settings = append(settings, fmt.Sprintf("Temperature: `%g`", *persona.Temperature))
+	}
+	switch {
+	case persona.Tools == nil:
+		settings = append(settings, "Tools: _all_")
+	case len(persona.Tools) == 0:
+		settings = append(settings, "Tools: _none_")
+	default:
+		settings = append(settings, fmt.Sprintf("Tools: %s", f.Join(f.Map(persona.Tools, func(t string) string { return "`" + t + "`" }), ", ")))
+	}
+
+	return f.Text(
+		fmt.Sprintf("*%s*", persona.Name),
+		fmt.Sprintf("> %s", strings.ReplaceAll(f.IfElse(len(prompt) > 0, string(prompt), "_(no prompt)_"), "\n", "\n> ")),
+		f.Join(settings, " · "),
+	)
+}
+
+// scoped gives back who the persona command is for, which is the user unless `--channel` is given
+func scoped(args rpc.Args, ctx AppContext) (ai.Scope, string, string, error) {
+	if !args.Bool("channel") {
+		return ai.UserScope, ctx.UserID, "your conversations", nil
+	}
+	if ctx.Channel == "" {
+		return "", "", "", rpc.UserErrorf("There is no channel here to pick a persona for")
+	}
+	return ai.ChannelScope, ctx.Channel, fmt.Sprintf("conversations in <#%s>", ctx.Channel), nil
+}
+
+// showPersona shows the persona the AI takes for the user here
+func showPersona(args rpc.Args, ctx AppContext) error {
+	persona := ai.Resolve(ai.Asker{UserID: ctx.UserID, Channel: ctx.Channel})
+	_, err := ctx.Client.PostEphemeral(
+		ctx.ReplyTo,
+		ctx.UserID,
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(
+					slack.MarkdownType,
+					f.Text(
+						fmt.Sprintf("%s This is who I am when you talk to me here", emoji.BIG_BRAIN),
+						describe(persona),
+					),
+					false,
+					false,
+				),
+				nil,
+				nil,
+			),
+		),
+	)
+	return err
+}
+
+// listPersonas lists every persona defined
+func listPersonas(args rpc.Args, ctx AppContext) error {
+	personas, err := ai.Personas()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = ctx.Client.PostMessage(
+		ctx.ReplyTo,
+		slack.MsgOptionBlocks(
+			append(
+				[]slack.Block{
+					slack.NewSectionBlock(
+						slack.NewTextBlockObject(
+							slack.MarkdownType,
+							fmt.Sprintf("%s *Personas I can take*", emoji.BIG_BRAIN),
+							false,
+							false,
+						),
+						nil,
+						nil,
+					),
+				},
+				personaBlocks(personas)...,
+			)...,
+		),
+	)
+	return err
+}
+
+// maxPersonaSections is the most sections of personas in the list, leaving room for the title and a note within Slack's 50 blocks
+const maxPersonaSections = 48
+
+// personaBlocks describes the personas with as many of them in each section as fit, and a note for the ones left out
+// if there are still too many to show in one message
+func personaBlocks(personas []ai.Persona) []slack.Block {
+	groups, counts := []string{}, []int{}
+	for _, persona := range personas {
+		description := describe(persona)
+		last := len(groups) - 1
+		if last >= 0 && utf8.RuneCountInString(groups[last]+"\n\n"+description) <= mrkdwn.MaxSectionText {
+			groups[last] += "\n\n" + description
+			counts[last]++
+			continue
+		}
+		groups = append(groups, description)
+		counts = append(counts, 1)
+	}
+
+	left := 0
+	if len(groups) > maxPersonaSections {
+		left = f.SumBy(counts[maxPersonaSections:], func(count int) int { return count })
+		groups = groups[:maxPersonaSections]
+	}
+
+	blocks := f.Map(groups, func(group string) slack.Block {
+		return slack.NewSectionBlock(
+			slack.NewTextBlockObject(slack.MarkdownType, group, false, false),
+			nil,
+			nil,
+		)
+	})
+	if left > 0 {
+		blocks = append(blocks, slack.NewContextBlock(
+			"",
+			slack.NewTextBlockObject(
+				slack.MarkdownType,
+				fmt.Sprintf("…and %d more persona(s) that don't fit in one message", left),
+				false,
+				false,
+			),
+		))
+	}
+	return blocks
+}
+
+// setPersona picks the persona for the user's conversations, or the channel's
+func setPersona(args rpc.Args, ctx AppContext) error {
+	scope, id, who, err := scoped(args, ctx)
+	if err != nil {
+		return err
+	}
+
+	name := strings.ToLower(args.String("name"))
+	if name != ai.DEFAULT_PERSONA {
+		_, ok, err := ai.FindPersona(name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return rpc.UserErrorf("There is no persona named `%s`, see `persona list` for the ones I can take", name)
+		}
+	}
+
+	if err := ai.Assign(scope, id, f.IfElse(name == ai.DEFAULT_PERSONA, "", name)); err != nil {
+		return err
+	}
+
+	_, err = ctx.Client.PostEphemeral(
+		ctx.ReplyTo,
+		ctx.UserID,
+		slack.MsgOptionText(
+			fmt.Sprintf("%s I will be *%s* for %s, starting from the next conversation", emoji.DONE, name, who),
+			false,
+		),
+	)
+	return err
+}
+
+// unsetPersona goes back to the default persona for the user's conversations, or the channel's
+func unsetPersona(args rpc.Args, ctx AppContext) error {
+	scope, id, who, err := scoped(args, ctx)
+	if err != nil {
+		return err
+	}
+	if err := ai.Assign(scope, id, ""); err != nil {
+		return err
+	}
+
+	_, err = ctx.Client.PostEphemeral(
+		ctx.ReplyTo,
+		ctx.UserID,
+		slack.MsgOptionText(
+			fmt.Sprintf("%s I will be back to *%s* for %s, starting from the next conversation", emoji.DONE, ai.DEFAULT_PERSONA, who),
+			false,
+		),
+	)
+	return err
+}
+
+// definePersona creates or replaces a persona with its prompt, and optionally its model, temperature, and tools
+func definePersona(args rpc.Args, ctx AppContext) error {
+	name := strings.ToLower(args.String("name"))
+	if !personaName.MatchString(name) {
+		return rpc.UserErrorf("`%s` is not a good name for a persona, use lowercase letters, numbers, `-` and `_` only", name)
+	}
+
+	persona := ai.Persona{
+		Name:   name,
+		Prompt: strings.Join(args.Strings("prompt"), " "),
+	}
+
+	if args.Has("model") {
+		if !ai.Allowed(args.String("model")) {
+			return rpc.UserErrorf("`%s` is not one of the models I can use, see `models` for the ones I can", args.String("model"))
+		}
+		persona.Model = args.String("model")
+	}
+
+	if args.Has("temperature") {
+		temperature, err := strconv.ParseFloat(args.String("temperature"), 32)
+		if err != nil || temperature < 0 || temperature > 2 {
+			return rpc.UserErrorf("The temperature has to be a number between 0 and 2, not `%s`", args.String("temperature"))
+		}
+		t := float32(temperature)
+		persona.Temperature = &t
+	}
+
+	if args.Has("tools") {
+		names := f.Map(tools(ctx), func(tool ai.Tool) string { return tool.Name() })
+		persona.Tools = []string{}
+		for _, tool := range strings.Split(args.String("tools"), ",") {
+			tool = strings.TrimSpace(tool)
+			if tool == "" || tool == "none" {
+				continue
+			}
+			if !f.IsMember(names, tool) {
+				return rpc.UserErrorf("`%s` is not one of my tools, which are %s", tool, f.Join(f.Map(names, func(n string) string { return "`" + n + "`" }), ", "))
+			}
+			persona.Tools = append(persona.Tools, tool)
+		}
+	}
+
+	if err := ai.Define(persona); err != nil {
+		return err
+	}
+
+	_, err := ctx.Client.PostEphemeral(
+		ctx.ReplyTo,
+		ctx.UserID,
+		slack.MsgOptionText(
+			f.Text(
+				fmt.Sprintf("%s I can now be *%s*, pick it with `persona set %s`", emoji.DONE, name, name),
+				describe(persona),
+			),
+			false,
+		),
+	)
+	return err
+}
+
+// deletePersona deletes a persona, where the ones who picked it go back to the default
+func deletePersona(args rpc.Args, ctx AppContext) error {
+	name := strings.ToLower(args.String("name"))
+	ok, err := ai.Undefine(name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return rpc.UserErrorf("There is no persona named `%s`, see `persona list` for the ones I can take", name)
+	}
+
+	_, err = ctx.Client.PostEphemeral(
+		ctx.ReplyTo,
+		ctx.UserID,
+		slack.MsgOptionText(fmt.Sprintf("%s I will no longer be *%s*", emoji.DONE, name), false),
+	)
+	return err
+}
@@ -0,0 +1,250 @@
+package app
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"d-exclaimation.me/relax/app/ai"
+	"d-exclaimation.me/relax/lib/f"
+	"d-exclaimation.me/relax/lib/mrkdwn"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// minPace and maxPace are how often a streamed message is updated at most, and at least when Slack slows it down
+	minPace = 1 * time.Second
+	maxPace = 30 * time.Second
+
+	// maxAttempts is how many times the last update is tried when Slack is rate limiting
+	maxAttempts = 3
+)
+
+// streamWriter writes an answer being streamed into the bot's reply, updating it as fast as Slack allows, and continuing
+// it in more messages once it is too long for one, where the answer is converted from Markdown to Block Kit as it goes
+type streamWriter struct {
+	client   *slack.Client
+	channel  string
+	threadTS string
+
+	// prefix is put before the answer (e.g. the mention of the user who asked)
+	prefix string
+
+	// generation is what the "Stop generating" button stops while the answer is being written
+	generation string
+
+	// ts is the first message, which is where the answer starts
+	ts string
+
+	// timestamps and written are the messages posted so far, and what was last written to them
+	timestamps []string
+	written    []string
+
+	// pace is how long to wait between updates, which slows down when Slack is rate limiting, until next
+	pace time.Duration
+	next time.Time
+
+	latest  chan string
+	closing chan struct{}
+	closed  chan struct{}
+}
+
+// newStreamWriter creates a writer replying to the channel (in the thread, if any)
+func newStreamWriter(client *slack.Client, channel string, threadTS string, prefix string, generation string) *streamWriter {
+	return &streamWriter{
+		client:     client,
+		channel:    channel,
+		threadTS:   threadTS,
+		prefix:     prefix,
+		generation: generation,
+		pace:       minPace,
+		latest:     make(chan string, 1),
+		closing:    make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+}
+
+// Start posts the first message of the reply, and keeps it updated with what is written until closed
+func (w *streamWriter) Start(text string) error {
+	if err := w.render(text, true); err != nil {
+		return err
+	}
+	w.ts = w.timestamps[0]
+
+	go func() {
+		defer close(w.closed)
+		for {
+			select {
+			case <-w.closing:
+				return
+			case text := <-w.latest:
+				select {
+				case <-time.After(time.Until(w.next)):
+				case <-w.closing:
+					return
+				}
+
+				// Only the latest is worth writing after waiting
+				select {
+				case text = <-w.latest:
+				default:
+				}
+
+				if err := w.render(text, true); err != nil {
+					log.Printf("Failed to update the streamed reply in %s: %s\n", w.channel, err.Error())
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+// TS is the timestamp of the first message of the answer, once started
+func (w *streamWriter) TS() string {
+	return w.ts
+}
+
+// Write replaces the reply with the answer so far, where only the latest is written once it is time to update
+func (w *streamWriter) Write(text string) {
+	select {
+	case <-w.latest:
+	default:
+	}
+	w.latest <- text
+}
+
+// Close writes the whole reply, retrying while Slack is rate limiting, and stops updating it,
+// where closing it again does nothing
+func (w *streamWriter) Close(text string) error {
+	select {
+	case <-w.closing:
+		return nil
+	default:
+	}
+	close(w.closing)
+	<-w.closed
+
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		time.Sleep(time.Until(w.next))
+
+		err = w.render(text, false)
+		if limited := (&slack.RateLimitedError{}); !errors.As(err, &limited) {
+			return err
+		}
+	}
+	return err
+}
+
+// render writes the text into the messages of the reply, posting a continuation for what doesn't fit,
+// skipping the messages that have not changed, and deleting the ones no longer needed (e.g. when a tool call
+// starts the answer over)
+func (w *streamWriter) render(text string, generating bool) error {
+	messages := w.messages(text)
+	for i, message := range messages {
+		// The button is always at the end of the reply
+		generation := f.IfElse(generating && i == len(messages)-1, w.generation, "")
+		content := generation + message.Text
+		if i < len(w.written) && w.written[i] == content {
+			continue
+		}
+
+		blocks := message.Blocks
+		if generation != "" {
+			blocks = append(blocks, ai.StopBlock(generation))
+		}
+		options := []slack.MsgOption{
+			slack.MsgOptionText(message.Text, false),
+			slack.MsgOptionBlocks(blocks...),
+		}
+
+		if i < len(w.timestamps) {
+			err := w.paced(func() error {
+				_, _, _, err := w.client.UpdateMessage(w.channel, w.timestamps[i], options...)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			w.written[i] = content
+			continue
+		}
+
+		err := w.paced(func() error {
+			// The channel given back is the actual conversation ID, even if the reply is addressed to a user
+			channel, timestamp, err := w.client.PostMessage(
+				w.channel,
+				append(options, f.IfElse(w.threadTS != "", []slack.MsgOption{slack.MsgOptionTS(w.threadTS)}, nil)...)...,
+			)
+			if err == nil {
+				w.channel = channel
+				w.timestamps = append(w.timestamps, timestamp)
+			}
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		w.written = append(w.written, content)
+	}
+
+	// Latest first, so the reply never has a gap in the middle
+	for i := len(w.timestamps) - 1; i >= len(messages); i-- {
+		err := w.paced(func() error {
+			_, _, err := w.client.DeleteMessage(w.channel, w.timestamps[i])
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		w.timestamps = w.timestamps[:i]
+		w.written = w.written[:i]
+	}
+	return nil
+}
+
+// messages converts the text into the messages of the reply, with the prefix at the start of the first one
+func (w *streamWriter) messages(text string) []mrkdwn.Message {
+	messages := mrkdwn.Messages(text)
+	if len(messages) == 0 {
+		messages = []mrkdwn.Message{{}}
+	}
+
+	first := &messages[0]
+	first.Text = w.prefix + first.Text
+
+	// The prefix goes in front of the first section, or in one of its own if the reply starts with something else
+	// (e.g. a header, a code block, or a section with no room left)
+	if len(first.Blocks) > 0 {
+		section, ok := first.Blocks[0].(*slack.SectionBlock)
+		if ok && section.Text != nil && !strings.HasPrefix(section.Text.Text, "```") &&
+			utf8.RuneCountInString(w.prefix+section.Text.Text) <= mrkdwn.MaxSectionText {
+			section.Text.Text = w.prefix + section.Text.Text
+			return messages
+		}
+	}
+	first.Blocks = append([]slack.Block{
+		slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, w.prefix, false, false), nil, nil),
+	}, first.Blocks...)
+	return messages
+}
+
+// paced calls Slack and adapts the pace of the updates, slowing down for as long as Slack asks when rate limited,
+// and speeding back up otherwise
+func (w *streamWriter) paced(call func() error) error {
+	err := call()
+
+	limited := &slack.RateLimitedError{}
+	if errors.As(err, &limited) {
+		w.pace = f.IfElse(w.pace*2 > maxPace, maxPace, w.pace*2)
+		w.next = time.Now().Add(f.IfElse(limited.RetryAfter > w.pace, limited.RetryAfter, w.pace))
+		return err
+	}
+
+	w.pace = f.IfElse(w.pace*3/4 < minPace, minPace, w.pace*3/4)
+	w.next = time.Now().Add(w.pace)
+	return err
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"d-exclaimation.me/relax/app/ai"
+	"d-exclaimation.me/relax/lib/f"
+	"d-exclaimation.me/relax/lib/kv"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// maxHistory is the most messages of a thread used to start an AI conversation
+const maxHistory = 20
+
+// Bot is who the bot itself is, to tell apart its own messages and mentions
+type Bot struct {
+	// UserID is the bot's user ID, used in mentions
+	UserID string
+
+	// BotID is the bot's ID, given to the messages it posts
+	BotID string
+}
+
+// Conversational returns true if the message is worth answering without a mention (i.e. not from a bot,
+// not an edit / deletion / join, and not a mention which is already handled as an app mention)
+func (b Bot) Conversational(event *slackevents.MessageEvent) bool {
+	switch {
+	case event.SubType != "" || event.BotID != "" || event.User == "" || event.User == b.UserID:
+		return false
+	case event.ChannelType != slack.TYPE_IM && strings.Contains(event.Text, "<@"+b.UserID):
+		return false
+	}
+	return true
+}
+
+// threadLifetime is how long a thread the AI answered in is still answered without a mention
+const threadLifetime = 7 * 24 * time.Hour
+
+// threadKey is where a thread the AI answered in is remembered
+func threadKey(channel string, ts string) string {
+	return "ai:thread:" + channel + ":" + ts
+}
+
+// rememberThread records that the AI answered in the thread, so the replies in it are answered without a mention
+func rememberThread(channel string, ts string) {
+	kv.SetEX(threadKey(channel, ts), "1", threadLifetime)
+}
+
+// threads remembers which threads hold an AI conversation, acting as a concurrent-safe actor
+type threads struct {
+	setter chan string
+	getter chan struct {
+		key string
+		out chan bool
+	}
+}
+
+// newThreads creates the cache of threads holding an AI conversation, and runs the actor
+func newThreads() *threads {
+	t := &threads{
+		setter: make(chan string),
+		getter: make(chan struct {
+			key string
+			out chan bool
+		}),
+	}
+
+	go func() {
+		// When each thread was found to hold an AI conversation
+		cache := make(map[string]time.Time)
+		swept := time.Now()
+		for {
+			select {
+			case key := <-t.setter:
+				cache[key] = time.Now()
+
+				// Forget the threads that are no longer relevant every now and then
+				if time.Since(swept) > time.Hour {
+					for key, at := range cache {
+						if time.Since(at) > time.Hour {
+							delete(cache, key)
+						}
+					}
+					swept = time.Now()
+				}
+			case g := <-t.getter:
+				_, ok := cache[g.key]
+				g.out <- ok
+			}
+		}
+	}()
+
+	return t
+}
+
+// Conversational returns true if the thread holds an AI conversation (i.e. the AI answered in it), so other threads
+// (e.g. under a reviewer pick or a meme) are left to the people in them
+// Only the threads known to hold one are cached, as the AI can start answering in any thread later on
+func (t *threads) Conversational(channel string, ts string) bool {
+	key := threadKey(channel, ts)
+
+	out := make(chan bool)
+	t.getter <- struct {
+		key string
+		out chan bool
+	}{key, out}
+	if <-out {
+		return true
+	}
+
+	answered, err := kv.Get(key).Await()
+	if err != nil || answered.Result == "" {
+		return false
+	}
+
+	t.setter <- key
+	return true
+}
+
+// leadingMention matches the mention at the start of a message
+var leadingMention = regexp.MustCompile(`^\s*<@[^>]+>\s*`)
+
+// direct returns true if the user is talking to the bot on their own (e.g. DMs, or the "Ask relax" modal)
+func direct(ctx AppContext) bool {
+	return ctx.Channel == "" || strings.HasPrefix(ctx.Channel, "D")
+}
+
+// conversation gives the key of the AI conversation, which is the thread if there is one, otherwise the user in DMs
+// (top level messages in channels are answered in their own thread, so they never share a conversation)
+func conversation(ctx AppContext) string {
+	if ctx.ThreadTS == "" && direct(ctx) {
+		return ctx.UserID
+	}
+	return ctx.Channel + ":" + ctx.ThreadTS
+}
+
+// history gives the messages in the thread before the one being answered, to start the AI conversation from
+// when the bot is mentioned mid-thread
+func history(ctx AppContext) ai.History {
+	// Nothing comes before the message starting the thread
+	if ctx.ThreadTS == "" || ctx.Channel == "" || ctx.ThreadTS == ctx.MessageTS {
+		return nil
+	}
+
+	return func() []ai.Message {
+		// Replies come oldest first, so go through every page to get the latest ones
+		replies, cursor := []slack.Message{}, ""
+		for {
+			page, more, next, err := ctx.Client.GetConversationReplies(&slack.GetConversationRepliesParameters{
+				ChannelID: ctx.Channel,
+				Timestamp: ctx.ThreadTS,
+				Latest:    ctx.MessageTS,
+				Cursor:    cursor,
+				Limit:     200,
+			})
+			if err != nil {
+				log.Printf("Failed to fetch the thread %s for the conversation: %s\n", ctx.ThreadTS, err.Error())
+				return nil
+			}
+			replies = append(replies, page...)
+			if !more || next == "" {
+				break
+			}
+			cursor = next
+		}
+
+		messages := f.Filter(replies, func(m slack.Message) bool {
+			return m.SubType == "" && m.Text != "" && (ctx.MessageTS == "" || m.Timestamp < ctx.MessageTS)
+		})
+		if len(messages) > maxHistory {
+			messages = messages[len(messages)-maxHistory:]
+		}
+
+		return f.Map(messages, func(m slack.Message) ai.Message {
+			text := leadingMention.ReplaceAllString(m.Text, "")
+			if m.User == ctx.Bot.UserID || (m.BotID != "" && m.BotID == ctx.Bot.BotID) {
+				return ai.AssistantMessage(text)
+			}
+			return ai.UserMessage(text)
+		})
+	}
+}
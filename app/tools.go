@@ -0,0 +1,134 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+
+	"d-exclaimation.me/relax/app/ai"
+	"d-exclaimation.me/relax/app/emoji"
+	"d-exclaimation.me/relax/app/memes"
+	"d-exclaimation.me/relax/app/mr"
+	"d-exclaimation.me/relax/app/quote"
+	"d-exclaimation.me/relax/lib/f"
+	"d-exclaimation.me/relax/lib/kv"
+	"github.com/sashabaranov/go-openai/jsonschema"
+	"github.com/slack-go/slack"
+)
+
+// slackUserID matches a Slack user ID, given as is or in a mention
+var slackUserID = regexp.MustCompile(`[UW][A-Z0-9]{2,}`)
+
+// tools are what the AI can call on behalf of the user, so its answers are grounded in real data
+func tools(ctx AppContext) ai.Tools {
+	return ai.Tools{
+		ai.NewTool(
+			"random_reviewer",
+			"Suggest a random reviewer from the team for the user's merge request, favouring whoever has done the fewest reviews. "+
+				"This does not count as a review, so tell the user to click the button given to them, or run `reviewer`, to pick one for real.",
+			func(args ai.ToolArgs) (any, error) {
+				// Only a suggestion, the review is counted once the user picks one with the `reviewer` command
+				reviewer, err := mr.ReadonlyRandomReviewer(ctx.Client, func(u slack.User) bool {
+					return u.IsRestricted || u.ID == ctx.UserID
+				})
+				if errors.Is(err, mr.ErrNoReviewers) {
+					return map[string]string{"error": "no one is available to review right now"}, nil
+				}
+				if err != nil {
+					return nil, err
+				}
+
+				// Let the user confirm it with a button, which picks a reviewer for real like the `reviewer` command
+				_, err = ctx.Client.PostEphemeral(
+					ctx.ReplyTo,
+					ctx.UserID,
+					slack.MsgOptionBlocks(mr.SuggestedReviewerBlocks(reviewer.User.ID, ctx.UserID)...),
+					slack.MsgOptionTS(ctx.ThreadTS),
+				)
+				if err != nil {
+					log.Printf("Failed to offer picking a reviewer to %s: %s\n", ctx.UserID, err.Error())
+				}
+
+				return map[string]any{
+					"reviewer": "<@" + reviewer.User.ID + ">",
+					"name":     reviewer.User.Profile.RealName,
+					"reviews":  reviewer.ReviewCount,
+					"note":     "only a suggestion, the user was given a button to pick a reviewer for real and count the review (or run `reviewer`)",
+				}, nil
+			},
+		),
+
+		ai.NewTool(
+			"reviewer_stats",
+			"Get how many reviews everyone in the team has done, and whether they are available to review",
+			func(args ai.ToolArgs) (any, error) {
+				reviewers, err := mr.TeamReviewers(ctx.Client)
+				if err != nil {
+					return nil, err
+				}
+				return f.Map(reviewers, func(r mr.Reviewer) map[string]any {
+					return map[string]any{
+						"user":      "<@" + r.User.ID + ">",
+						"name":      r.User.Profile.RealName,
+						"reviews":   r.ReviewCount,
+						"available": r.User.Profile.StatusEmoji != emoji.BRB,
+					}
+				}), nil
+			},
+		),
+
+		ai.NewTool(
+			"review_count",
+			"Look up how many reviews a single person has done",
+			func(args ai.ToolArgs) (any, error) {
+				user := slackUserID.FindString(args.String("user"))
+				if user == "" {
+					return nil, fmt.Errorf("%s is not a Slack user ID", args.String("user"))
+				}
+				res, err := kv.Get("reviews:" + user).Await()
+				if err != nil {
+					return nil, err
+				}
+				return map[string]any{
+					"user":    "<@" + user + ">",
+					"reviews": f.ParseInt(res.Result),
+				}, nil
+			},
+		).
+			Param("user", jsonschema.String, "The Slack user ID, e.g. U012AB3CD").
+			Required("user"),
+
+		ai.NewTool(
+			"random_quote",
+			"Get a random quote from a famous person",
+			func(args ai.ToolArgs) (any, error) {
+				q, err := quote.Random().Await()
+				if err != nil {
+					return nil, err
+				}
+				return map[string]string{
+					"content": q.Content,
+					"author":  q.Author,
+				}, nil
+			},
+		),
+
+		ai.NewTool(
+			"random_meme",
+			"Get a random meme from reddit, where the link can be shared with the user",
+			func(args ai.ToolArgs) (any, error) {
+				m, err := memes.Random().Await()
+				if err != nil {
+					return nil, err
+				}
+				return map[string]string{
+					"title":  m.Title,
+					"image":  m.URL,
+					"link":   m.PostLink,
+					"author": m.Author,
+				}, nil
+			},
+		),
+	}
+}
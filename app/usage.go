@@ -0,0 +1,102 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"d-exclaimation.me/relax/app/ai"
+	"d-exclaimation.me/relax/app/emoji"
+	"d-exclaimation.me/relax/lib/f"
+	"d-exclaimation.me/relax/lib/rpc"
+	"github.com/slack-go/slack"
+)
+
+// thousands formats the number with commas between the thousands
+func thousands(n int) string {
+	digits := strconv.Itoa(n)
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
+
+// when shows the time in the user's own time zone
+func when(t time.Time) string {
+	return fmt.Sprintf("<!date^%d^{date_short_pretty}|%s>", t.Unix(), t.Format("2006-01-02"))
+}
+
+// today is how the period is called while it is going on
+func today(period ai.Period) string {
+	return f.IfElse(period == ai.Daily, "today", "this month")
+}
+
+// overQuota is the notice for a question not answered because the user or the channel used up their tokens
+func overQuota(spend ai.Spend) string {
+	return fmt.Sprintf(
+		"_%s used up the AI tokens for %s (%s of %s), the quota resets %s_ %s",
+		f.IfElse(spend.Scope == ai.UserScope, "You have", "This channel has"),
+		today(spend.Period),
+		thousands(spend.Usage.Total()),
+		thousands(spend.Quota),
+		when(spend.Period.Resets(time.Now())),
+		emoji.OVERWORK,
+	)
+}
+
+// usage shows the AI tokens the user and the channel spent today and this month, with their quotas
+func usage(args rpc.Args, ctx AppContext) error {
+	spends, err := ai.Spending(ai.Asker{UserID: ctx.UserID, Channel: ctx.Channel})
+	if err != nil {
+		return err
+	}
+
+	lines := []string{fmt.Sprintf("%s *AI usage*", emoji.BIG_BRAIN)}
+	for i, spend := range spends {
+		if i == 0 || spends[i-1].ID != spend.ID {
+			switch {
+			case spend.Scope == ai.UserScope:
+				lines = append(lines, "*You*")
+			case strings.HasPrefix(spend.ID, "D"):
+				lines = append(lines, "*This conversation*")
+			default:
+				lines = append(lines, fmt.Sprintf("*<#%s>*", spend.ID))
+			}
+		}
+
+		line := fmt.Sprintf(
+			"> %s: *%s* tokens (%s prompt · %s completion)",
+			f.IfElse(spend.Period == ai.Daily, "Today", "This month"),
+			thousands(spend.Usage.Total()),
+			thousands(spend.Usage.Prompt),
+			thousands(spend.Usage.Completion),
+		)
+		if spend.Quota > 0 {
+			line += fmt.Sprintf(" of %s%s", thousands(spend.Quota), f.IfElse(spend.Over(), " "+emoji.OVERWORK, ""))
+		}
+		lines = append(lines, line)
+	}
+
+	_, err = ctx.Client.PostEphemeral(
+		ctx.ReplyTo,
+		ctx.UserID,
+		slack.MsgOptionBlocks(
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(slack.MarkdownType, f.Text(lines...), false, false),
+				nil,
+				nil,
+			),
+			slack.NewContextBlock(
+				"",
+				slack.NewTextBlockObject(
+					slack.MarkdownType,
+					"The tokens of streamed answers are approximated, and the days and months are in UTC",
+					false,
+					false,
+				),
+			),
+		),
+	)
+	return err
+}
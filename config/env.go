@@ -2,8 +2,10 @@ package config
 
 import (
 	"os"
+	"strconv"
 	"strings"
 
+	"d-exclaimation.me/relax/lib/f"
 	"github.com/joho/godotenv"
 )
 
@@ -18,21 +20,66 @@ const (
 	AI_TOKEN       = "AI_TOKEN"
 	AI_CONTEXT     = "AI_CONTEXT"
 	CHANNELS       = "CHANNEL_IDS"
+	ADMINS         = "ADMIN_IDS"
 	GO_ENV         = "GO_ENV"
+	EVENT_SOURCE   = "EVENT_SOURCE"
+	SIGNING_SECRET = "SIGNING_SECRET"
+	PORT           = "PORT"
+	AI_STORE       = "AI_STORE"
+	AI_BUDGET      = "AI_TOKEN_BUDGET"
+	AI_SUMMARIZE   = "AI_SUMMARIZE"
+	AI_MODEL       = "AI_MODEL"
+	AI_MODELS      = "AI_MODELS"
+	AI_TEMPERATURE = "AI_TEMPERATURE"
+	AI_PRESENCE    = "AI_PRESENCE_PENALTY"
+	AI_FREQUENCY   = "AI_FREQUENCY_PENALTY"
+	AI_BASE_URL    = "AI_BASE_URL"
+	AI_API_TYPE    = "AI_API_TYPE"
+	AI_API_VERSION = "AI_API_VERSION"
+	AI_ORG         = "AI_ORG"
+	AI_DEPLOYMENTS = "AI_DEPLOYMENTS"
+
+	AI_USER_DAILY_QUOTA      = "AI_USER_DAILY_QUOTA"
+	AI_USER_MONTHLY_QUOTA    = "AI_USER_MONTHLY_QUOTA"
+	AI_CHANNEL_DAILY_QUOTA   = "AI_CHANNEL_DAILY_QUOTA"
+	AI_CHANNEL_MONTHLY_QUOTA = "AI_CHANNEL_MONTHLY_QUOTA"
 )
 
 // Environment is a struct that holds the environment variables
 type Environment struct {
 	oauth     string
 	channels  []string
+	admins    []string
 	mode      string
 	oauthApp  string
+	appName   string
 	quoteAPI  string
 	memeAPI   string
 	kvURL     string
 	kvToken   string
 	aiToken   string
 	aiContext string
+	source    string
+	secret    string
+	port      string
+	aiStore   string
+	aiBudget  int
+	summarize string
+	aiModel   string
+	aiModels  []string
+	aiTemp    string
+	presence  string
+	frequency string
+	aiBaseURL string
+	aiAPIType string
+	aiVersion string
+	aiOrg     string
+	aiDeploys map[string]string
+
+	userDaily      int
+	userMonthly    int
+	channelDaily   int
+	channelMonthly int
 }
 
 // Env is a global environment variables
@@ -47,13 +94,35 @@ func (*Environment) Load() {
 	Env.oauth = GetOAuthEnv()
 	Env.mode = mode
 	Env.channels = GetChannelsEnv()
+	Env.admins = GetAdminsEnv()
 	Env.oauthApp = GetOAuthAppEnv()
+	Env.appName = GetOAuthAppNameEnv()
 	Env.quoteAPI = GetQuoteAPIURL()
 	Env.kvURL = GetKVURL()
 	Env.kvToken = GetKVToken()
 	Env.aiToken = GetAIToken()
 	Env.aiContext = GetAIContext()
 	Env.memeAPI = GetMemeAPIURL()
+	Env.source = GetEventSourceEnv()
+	Env.secret = GetSigningSecretEnv()
+	Env.port = GetPortEnv()
+	Env.aiStore = GetAIStoreEnv()
+	Env.aiBudget = GetAIBudgetEnv()
+	Env.summarize = GetAISummarizeEnv()
+	Env.aiModel = GetAIModelEnv()
+	Env.aiModels = GetAIModelsEnv()
+	Env.aiTemp = GetAITemperatureEnv()
+	Env.presence = GetAIPresencePenaltyEnv()
+	Env.frequency = GetAIFrequencyPenaltyEnv()
+	Env.aiBaseURL = GetAIBaseURLEnv()
+	Env.aiAPIType = GetAIAPITypeEnv()
+	Env.aiVersion = GetAIAPIVersionEnv()
+	Env.aiOrg = GetAIOrgEnv()
+	Env.aiDeploys = GetAIDeploymentsEnv()
+	Env.userDaily = GetAIUserDailyQuotaEnv()
+	Env.userMonthly = GetAIUserMonthlyQuotaEnv()
+	Env.channelDaily = GetAIChannelDailyQuotaEnv()
+	Env.channelMonthly = GetAIChannelMonthlyQuotaEnv()
 }
 
 // OAuth lazily load and returns the OAuth token
@@ -65,6 +134,15 @@ func (e *Environment) OAuth() string {
 	return res
 }
 
+// Admins lazily load and returns the users allowed to change the bot for everyone (e.g. the personas)
+func (e *Environment) Admins() []string {
+	res := e.admins
+	if res == nil {
+		res = GetAdminsEnv()
+	}
+	return res
+}
+
 // OAuthApp lazily load and returns the OAuth app token
 func (e *Environment) OAuthApp() string {
 	res := e.oauthApp
@@ -74,6 +152,18 @@ func (e *Environment) OAuthApp() string {
 	return res
 }
 
+// OAuthAppName lazily load and returns the app name (defaults to relax)
+func (e *Environment) OAuthAppName() string {
+	res := e.appName
+	if res == "" {
+		res = GetOAuthAppNameEnv()
+	}
+	if res == "" {
+		res = "relax"
+	}
+	return res
+}
+
 // Channels lazily load andreturns the channels
 func (e *Environment) Channels() []string {
 	res := e.channels
@@ -146,6 +236,210 @@ func (e *Environment) AIContext() string {
 	return res
 }
 
+// EventSource lazily load and returns where the events come from, either socket (default) or http
+func (e *Environment) EventSource() string {
+	res := e.source
+	if res == "" {
+		res = GetEventSourceEnv()
+	}
+	if res == "" {
+		res = "socket"
+	}
+	return res
+}
+
+// SigningSecret lazily load and returns the signing secret to verify the requests from Slack
+func (e *Environment) SigningSecret() string {
+	res := e.secret
+	if res == "" {
+		res = GetSigningSecretEnv()
+	}
+	return res
+}
+
+// Port lazily load and returns the port to listen on for the HTTP events (defaults to 8080)
+func (e *Environment) Port() string {
+	res := e.port
+	if res == "" {
+		res = GetPortEnv()
+	}
+	if res == "" {
+		res = "8080"
+	}
+	return res
+}
+
+// AIStore lazily load and returns where the AI conversations are kept, either memory (default) or kv
+func (e *Environment) AIStore() string {
+	res := e.aiStore
+	if res == "" {
+		res = GetAIStoreEnv()
+	}
+	if res == "" {
+		res = "memory"
+	}
+	return res
+}
+
+// AIBudget lazily load and returns the most tokens of conversation history sent to the AI (defaults to 3000)
+func (e *Environment) AIBudget() int {
+	res := e.aiBudget
+	if res <= 0 {
+		res = GetAIBudgetEnv()
+	}
+	if res <= 0 {
+		res = 3000
+	}
+	return res
+}
+
+// AISummarize lazily load and returns true if the history trimmed off is replaced with a summary
+func (e *Environment) AISummarize() bool {
+	res := e.summarize
+	if res == "" {
+		res = GetAISummarizeEnv()
+	}
+	return res == "true"
+}
+
+// AIModel lazily load and returns the default AI model (defaults to gpt-3.5-turbo)
+func (e *Environment) AIModel() string {
+	res := e.aiModel
+	if res == "" {
+		res = GetAIModelEnv()
+	}
+	if res == "" {
+		res = "gpt-3.5-turbo"
+	}
+	return res
+}
+
+// AIModels lazily load and returns the AI models allowed to be picked (defaults to only the default model)
+func (e *Environment) AIModels() []string {
+	res := e.aiModels
+	if res == nil {
+		res = GetAIModelsEnv()
+	}
+	if !f.IsMember(res, e.AIModel()) {
+		res = append([]string{e.AIModel()}, res...)
+	}
+	return res
+}
+
+// AITemperature lazily load and returns the default AI temperature (defaults to 1)
+func (e *Environment) AITemperature() float32 {
+	res := e.aiTemp
+	if res == "" {
+		res = GetAITemperatureEnv()
+	}
+	return parseFloat(res, 1)
+}
+
+// AIPresencePenalty lazily load and returns the default AI presence penalty (defaults to 2)
+func (e *Environment) AIPresencePenalty() float32 {
+	res := e.presence
+	if res == "" {
+		res = GetAIPresencePenaltyEnv()
+	}
+	return parseFloat(res, 2)
+}
+
+// AIFrequencyPenalty lazily load and returns the default AI frequency penalty (defaults to 0)
+func (e *Environment) AIFrequencyPenalty() float32 {
+	res := e.frequency
+	if res == "" {
+		res = GetAIFrequencyPenaltyEnv()
+	}
+	return parseFloat(res, 0)
+}
+
+// AIBaseURL lazily load and returns the base URL of an OpenAI-compatible API (empty for OpenAI itself)
+func (e *Environment) AIBaseURL() string {
+	res := e.aiBaseURL
+	if res == "" {
+		res = GetAIBaseURLEnv()
+	}
+	return res
+}
+
+// AIAPIType lazily load and returns the type of the AI API, either openai (default), azure, or azure_ad
+func (e *Environment) AIAPIType() string {
+	res := e.aiAPIType
+	if res == "" {
+		res = GetAIAPITypeEnv()
+	}
+	if res == "" {
+		res = "openai"
+	}
+	return strings.ToLower(res)
+}
+
+// AIAPIVersion lazily load and returns the version of the Azure OpenAI API (defaults to 2023-05-15)
+func (e *Environment) AIAPIVersion() string {
+	res := e.aiVersion
+	if res == "" {
+		res = GetAIAPIVersionEnv()
+	}
+	if res == "" {
+		res = "2023-05-15"
+	}
+	return res
+}
+
+// AIOrg lazily load and returns the OpenAI organization ID
+func (e *Environment) AIOrg() string {
+	res := e.aiOrg
+	if res == "" {
+		res = GetAIOrgEnv()
+	}
+	return res
+}
+
+// AIDeployments lazily load and returns the Azure deployment names by model
+func (e *Environment) AIDeployments() map[string]string {
+	res := e.aiDeploys
+	if res == nil {
+		res = GetAIDeploymentsEnv()
+	}
+	return res
+}
+
+// AIUserDailyQuota lazily load and returns the most AI tokens a user can spend in a day (0 for no limit)
+func (e *Environment) AIUserDailyQuota() int {
+	res := e.userDaily
+	if res <= 0 {
+		res = GetAIUserDailyQuotaEnv()
+	}
+	return res
+}
+
+// AIUserMonthlyQuota lazily load and returns the most AI tokens a user can spend in a month (0 for no limit)
+func (e *Environment) AIUserMonthlyQuota() int {
+	res := e.userMonthly
+	if res <= 0 {
+		res = GetAIUserMonthlyQuotaEnv()
+	}
+	return res
+}
+
+// AIChannelDailyQuota lazily load and returns the most AI tokens spent in a channel in a day (0 for no limit)
+func (e *Environment) AIChannelDailyQuota() int {
+	res := e.channelDaily
+	if res <= 0 {
+		res = GetAIChannelDailyQuotaEnv()
+	}
+	return res
+}
+
+// AIChannelMonthlyQuota lazily load and returns the most AI tokens spent in a channel in a month (0 for no limit)
+func (e *Environment) AIChannelMonthlyQuota() int {
+	res := e.channelMonthly
+	if res <= 0 {
+		res = GetAIChannelMonthlyQuotaEnv()
+	}
+	return res
+}
+
 // IsProduction returns true if the mode is production
 func (e *Environment) IsProduction() bool {
 	return e.Mode() == "production"
@@ -171,6 +465,15 @@ func GetChannelsEnv() []string {
 	return strings.Split(res, ",")
 }
 
+// GetAdminsEnv returns the admin users from the environment directly
+func GetAdminsEnv() []string {
+	res := os.Getenv(ADMINS)
+	if res == "" {
+		return []string{}
+	}
+	return f.Map(strings.Split(res, ","), strings.TrimSpace)
+}
+
 // GetOAuthAppEnv returns the OAuth app token from the environment directly
 func GetOAuthAppEnv() string {
 	return os.Getenv(OAUTH_APP)
@@ -210,3 +513,124 @@ func GetAIToken() string {
 func GetAIContext() string {
 	return os.Getenv(AI_CONTEXT)
 }
+
+// GetEventSourceEnv returns the event source from the environment directly
+func GetEventSourceEnv() string {
+	return os.Getenv(EVENT_SOURCE)
+}
+
+// GetSigningSecretEnv returns the signing secret from the environment directly
+func GetSigningSecretEnv() string {
+	return os.Getenv(SIGNING_SECRET)
+}
+
+// GetPortEnv returns the port from the environment directly
+func GetPortEnv() string {
+	return os.Getenv(PORT)
+}
+
+// GetAIStoreEnv returns the AI conversation store from the environment directly
+func GetAIStoreEnv() string {
+	return os.Getenv(AI_STORE)
+}
+
+// GetAIBudgetEnv returns the AI token budget from the environment directly
+func GetAIBudgetEnv() int {
+	return f.ParseInt(os.Getenv(AI_BUDGET))
+}
+
+// GetAISummarizeEnv returns whether to summarize the trimmed AI history from the environment directly
+func GetAISummarizeEnv() string {
+	return os.Getenv(AI_SUMMARIZE)
+}
+
+// GetAIModelEnv returns the default AI model from the environment directly
+func GetAIModelEnv() string {
+	return os.Getenv(AI_MODEL)
+}
+
+// GetAIModelsEnv returns the allowed AI models from the environment directly
+func GetAIModelsEnv() []string {
+	res := os.Getenv(AI_MODELS)
+	if res == "" {
+		return []string{}
+	}
+	return f.Map(strings.Split(res, ","), strings.TrimSpace)
+}
+
+// GetAITemperatureEnv returns the default AI temperature from the environment directly
+func GetAITemperatureEnv() string {
+	return os.Getenv(AI_TEMPERATURE)
+}
+
+// GetAIPresencePenaltyEnv returns the default AI presence penalty from the environment directly
+func GetAIPresencePenaltyEnv() string {
+	return os.Getenv(AI_PRESENCE)
+}
+
+// GetAIFrequencyPenaltyEnv returns the default AI frequency penalty from the environment directly
+func GetAIFrequencyPenaltyEnv() string {
+	return os.Getenv(AI_FREQUENCY)
+}
+
+// parseFloat parses a decimal number, or gives back the fallback if it is not one
+func parseFloat(s string, fallback float32) float32 {
+	res, err := strconv.ParseFloat(s, 32)
+	if err != nil {
+		return fallback
+	}
+	return float32(res)
+}
+
+// GetAIBaseURLEnv returns the AI base URL from the environment directly
+func GetAIBaseURLEnv() string {
+	return os.Getenv(AI_BASE_URL)
+}
+
+// GetAIAPITypeEnv returns the AI API type from the environment directly
+func GetAIAPITypeEnv() string {
+	return os.Getenv(AI_API_TYPE)
+}
+
+// GetAIAPIVersionEnv returns the AI API version from the environment directly
+func GetAIAPIVersionEnv() string {
+	return os.Getenv(AI_API_VERSION)
+}
+
+// GetAIOrgEnv returns the AI organization ID from the environment directly
+func GetAIOrgEnv() string {
+	return os.Getenv(AI_ORG)
+}
+
+// GetAIDeploymentsEnv returns the AI deployment names by model (as `model=deployment,...`) from the environment directly
+func GetAIDeploymentsEnv() map[string]string {
+	res := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(AI_DEPLOYMENTS), ",") {
+		model, deployment, ok := strings.Cut(pair, "=")
+		if !ok {
+			continue
+		}
+		res[strings.TrimSpace(model)] = strings.TrimSpace(deployment)
+	}
+	return res
+}
+
+// GetAIUserDailyQuotaEnv returns the daily AI token quota of a user from the environment directly
+func GetAIUserDailyQuotaEnv() int {
+	return f.ParseInt(os.Getenv(AI_USER_DAILY_QUOTA))
+}
+
+// GetAIUserMonthlyQuotaEnv returns the monthly AI token quota of a user from the environment directly
+func GetAIUserMonthlyQuotaEnv() int {
+	return f.ParseInt(os.Getenv(AI_USER_MONTHLY_QUOTA))
+}
+
+// GetAIChannelDailyQuotaEnv returns the daily AI token quota of a channel from the environment directly
+func GetAIChannelDailyQuotaEnv() int {
+	return f.ParseInt(os.Getenv(AI_CHANNEL_DAILY_QUOTA))
+}
+
+// GetAIChannelMonthlyQuotaEnv returns the monthly AI token quota of a channel from the environment directly
+func GetAIChannelMonthlyQuotaEnv() int {
+	return f.ParseInt(os.Getenv(AI_CHANNEL_MONTHLY_QUOTA))
+}
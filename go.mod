@@ -3,8 +3,9 @@ module d-exclaimation.me/relax
 go 1.20
 
 require (
-	github.com/gorilla/websocket v1.5.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/sashabaranov/go-openai v1.14.0 // indirect
-	github.com/slack-go/slack v0.12.2 // indirect
+	github.com/joho/godotenv v1.5.1
+	github.com/sashabaranov/go-openai v1.14.0
+	github.com/slack-go/slack v0.12.2
 )
+
+require github.com/gorilla/websocket v1.5.0 // indirect
@@ -0,0 +1,45 @@
+package async
+
+import (
+	"context"
+	"sync"
+)
+
+// Group keeps track of the tasks still in flight, to wait for them (e.g. before shutting down)
+type Group struct {
+	wg sync.WaitGroup
+}
+
+// Go runs the action on a separate goroutine as part of the group
+func (g *Group) Go(action func()) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		action()
+	}()
+}
+
+// Wait waits for every task in the group, or gives back the context's error if it ends first
+func (g *Group) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run creates a new task from an asynchronous action, as part of the group
+func Run[T any](g *Group, action func() (T, error)) Task[T] {
+	g.wg.Add(1)
+	return New(func() (T, error) {
+		defer g.wg.Done()
+		return action()
+	})
+}
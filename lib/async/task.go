@@ -1,5 +1,7 @@
 package async
 
+import "context"
+
 // Unit is a type that represents a void
 type Unit struct{}
 
@@ -23,8 +25,9 @@ type Task[T any] struct {
 }
 
 // New creates a new task from an asynchronous action
+// The result is buffered, so the action can finish even if the task is never awaited
 func New[T any](action func() (T, error)) Task[T] {
-	channel := make(chan Result[T])
+	channel := make(chan Result[T], 1)
 	go func() {
 		result, err := action()
 		channel <- Result[T]{
@@ -55,3 +58,14 @@ func (t Task[T]) Await() (T, error) {
 	res := <-t.channel
 	return res.Result, res.Error
 }
+
+// AwaitContext waits for the task to be resolved or rejected, or gives back the context's error if it ends first
+func (t Task[T]) AwaitContext(ctx context.Context) (T, error) {
+	select {
+	case res := <-t.channel:
+		return res.Result, res.Error
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
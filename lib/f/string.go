@@ -31,3 +31,27 @@ func ParseInt(s string) int {
 	}
 	return marks
 }
+
+// EditDistance returns the minimum number of single character edits to turn a into b (Levenshtein distance)
+func EditDistance(a string, b string) int {
+	source, target := []rune(a), []rune(b)
+	prev := make([]int, len(target)+1)
+	curr := make([]int, len(target)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		curr[0] = i
+		for j := 1; j <= len(target); j++ {
+			cost := IfElse(source[i-1] == target[j-1], 0, 1)
+			curr[j] = IfElse(prev[j] < curr[j-1], prev[j], curr[j-1]) + 1
+			if prev[j-1]+cost < curr[j] {
+				curr[j] = prev[j-1] + cost
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(target)]
+}
@@ -2,9 +2,12 @@ package kv
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"d-exclaimation.me/relax/config"
 	"d-exclaimation.me/relax/lib/async"
@@ -15,6 +18,9 @@ import (
 type KVPacket[Data any] struct {
 	// The data itself.
 	Result Data `json:"result"`
+
+	// The error from the KV store, if any.
+	Error string `json:"error,omitempty"`
 }
 
 // KVPrimitive is a generic primitive type for the KV store.
@@ -32,12 +38,30 @@ const (
 	get  = "GET"
 	set  = "SET"
 	incr = "INCR"
+	decr = "DECR"
 	mget = "MGET"
+	nx   = "NX"
+	ex   = "EX"
+	del  = "DEL"
+
+	hget    = "HGET"
+	hset    = "HSET"
+	hdel    = "HDEL"
+	hgetall = "HGETALL"
 )
 
+// pending keeps track of the requests to the KV store still in flight
+var pending async.Group
+
+// Flush waits for every request to the KV store still in flight (e.g. writes that are never awaited),
+// or gives back the context's error if it ends first
+func Flush(ctx context.Context) error {
+	return pending.Wait(ctx)
+}
+
 // Command is a generic command to the KV store.
 func Command[Data any](name string, args ...any) async.Task[KVPacket[Data]] {
-	return async.New(func() (KVPacket[Data], error) {
+	return async.Run(&pending, func() (KVPacket[Data], error) {
 		command := make([]any, len(args)+1)
 		command[0] = name
 		for i, arg := range args {
@@ -69,13 +93,16 @@ func Command[Data any](name string, args ...any) async.Task[KVPacket[Data]] {
 		if err != nil {
 			return data, err
 		}
+		if data.Error != "" {
+			return data, errors.New(data.Error)
+		}
 		return data, nil
 	})
 }
 
 // Pipeline is a generic command to the KV store.
 func Pipeline[Data any](args ...KVCommand) async.Task[[]KVPacket[Data]] {
-	return async.New(func() ([]KVPacket[Data], error) {
+	return async.Run(&pending, func() ([]KVPacket[Data], error) {
 		commands := make([][]any, len(args))
 		for i, arg := range args {
 			commands[i] = make([]any, len(arg.Args)+1)
@@ -136,10 +163,27 @@ func Set[Data any](key string, value Data) async.Task[KVPacket[Data]] {
 	return Command[Data](set, key, value)
 }
 
+// SetEX sets a value by their key, and expires it after the TTL
+func SetEX[Data any](key string, value Data, ttl time.Duration) async.Task[KVPacket[string]] {
+	return Command[string](set, key, value, ex, int(ttl.Seconds()))
+}
+
+// SetNX sets a value by their key only if it does not exist yet, and expires it after the TTL
+// It returns true if the value was set
+func SetNX[Data any](key string, value Data, ttl time.Duration) async.Task[bool] {
+	return async.Run(&pending, func() (bool, error) {
+		res, err := Command[*string](set, key, value, nx, ex, int(ttl.Seconds())).Await()
+		if err != nil {
+			return false, err
+		}
+		return res.Result != nil, nil
+	})
+}
+
 // Incr increments an integer value by their key and returns the value
 // If the key does not exist, it will be created with the value 0 before
 func Incr(key string) async.Task[KVPacket[int]] {
-	return async.New(func() (KVPacket[int], error) {
+	return async.Run(&pending, func() (KVPacket[int], error) {
 		str, err := Command[string](incr, key).Await()
 		if err != nil {
 			return KVPacket[int]{}, err
@@ -147,3 +191,70 @@ func Incr(key string) async.Task[KVPacket[int]] {
 		return KVPacket[int]{Result: f.ParseInt(str.Result)}, nil
 	})
 }
+
+// Decr decrements an integer value by their key and returns the value
+// If the key does not exist, it will be created with the value 0 before
+func Decr(key string) async.Task[KVPacket[int]] {
+	return async.Run(&pending, func() (KVPacket[int], error) {
+		str, err := Command[string](decr, key).Await()
+		if err != nil {
+			return KVPacket[int]{}, err
+		}
+		return KVPacket[int]{Result: f.ParseInt(str.Result)}, nil
+	})
+}
+
+// Del deletes the keys and returns how many were deleted
+func Del(keys ...string) async.Task[KVPacket[int]] {
+	args := f.Map(keys, func(key string) any { return key })
+	return Command[int](del, args...)
+}
+
+// HGet gets a field of a hash by their key
+func HGet(key string, field string) async.Task[KVPacket[string]] {
+	return Command[string](hget, key, field)
+}
+
+// HSet sets a field of a hash by their key and returns how many fields were added
+func HSet[Data any](key string, field string, value Data) async.Task[KVPacket[int]] {
+	return Command[int](hset, key, field, value)
+}
+
+// HDel deletes a field of a hash by their key and returns how many fields were deleted
+func HDel(key string, field string) async.Task[KVPacket[int]] {
+	return Command[int](hdel, key, field)
+}
+
+// HGetAll gets every field of a hash by their key
+func HGetAll(key string) async.Task[KVPacket[map[string]string]] {
+	return async.Run(&pending, func() (KVPacket[map[string]string], error) {
+		res, err := Command[[]string](hgetall, key).Await()
+		if err != nil {
+			return KVPacket[map[string]string]{}, err
+		}
+		return KVPacket[map[string]string]{Result: fields(res.Result)}, nil
+	})
+}
+
+// HGetAllOf gets every field of multiple hashes by their keys
+func HGetAllOf(keys ...string) async.Task[[]KVPacket[map[string]string]] {
+	return async.Run(&pending, func() ([]KVPacket[map[string]string], error) {
+		args := f.Map(keys, func(key string) KVCommand { return KVCommand{Name: hgetall, Args: []any{key}} })
+		res, err := Pipeline[[]string](args...).Await()
+		if err != nil {
+			return nil, err
+		}
+		return f.Map(res, func(packet KVPacket[[]string]) KVPacket[map[string]string] {
+			return KVPacket[map[string]string]{Result: fields(packet.Result), Error: packet.Error}
+		}), nil
+	})
+}
+
+// fields pairs up the fields and values of a hash, which come one after another
+func fields(pairs []string) map[string]string {
+	res := make(map[string]string, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		res[pairs[i]] = pairs[i+1]
+	}
+	return res
+}
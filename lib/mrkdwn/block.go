@@ -0,0 +1,206 @@
+package mrkdwn
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"d-exclaimation.me/relax/lib/f"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// MaxSectionText is the most characters of the text in a section block
+	MaxSectionText = 3000
+
+	// maxHeaderText is the most characters of the text in a header block
+	maxHeaderText = 150
+
+	// maxMessageText is the most characters of a message
+	maxMessageText = 40000
+
+	// maxBlocks is the most blocks of a message, leaving room for a few of the caller's own (e.g. buttons)
+	maxBlocks = 45
+)
+
+var (
+	thematicBreak = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_])){2,}\s*$`)
+	tableDivider  = regexp.MustCompile(`^\s*\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?\s*$`)
+)
+
+// Message is a single Slack message of the converted Markdown, with the text shown in notifications
+type Message struct {
+	Text   string
+	Blocks []slack.Block
+}
+
+// block is a converted block, with its text for the message's notification
+type block struct {
+	slack.Block
+	text string
+}
+
+// Messages converts the Markdown into Block Kit, split into as many messages as needed to stay within Slack's limits
+func Messages(markdown string) []Message {
+	messages := []Message{}
+	current, size := Message{}, 0
+	for _, b := range parse(markdown) {
+		length := utf8.RuneCountInString(b.text)
+		if len(current.Blocks) >= maxBlocks || (len(current.Blocks) > 0 && size+length+1 > maxMessageText) {
+			messages = append(messages, current)
+			current, size = Message{}, 0
+		}
+		current.Blocks = append(current.Blocks, b.Block)
+		current.Text = strings.TrimPrefix(current.Text+"\n"+b.text, "\n")
+		size += length + 1
+	}
+
+	if len(current.Blocks) > 0 {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
+// parse goes through the Markdown line by line, and groups the lines into blocks
+func parse(markdown string) []block {
+	blocks := []block{}
+	lines := strings.Split(markdown, "\n")
+
+	// The lines of the paragraphs, lists, and quotes so far, which all go into sections
+	paragraph := []string{}
+	flush := func() {
+		text := strings.Trim(strings.Join(paragraph, "\n"), "\n")
+		paragraph = nil
+		if strings.TrimSpace(text) == "" {
+			return
+		}
+		for _, chunk := range Split(text, MaxSectionText) {
+			blocks = append(blocks, section(chunk))
+		}
+	}
+
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		switch {
+		// Code block, which goes until the closing fence (or the end, while still being streamed)
+		case strings.HasPrefix(strings.TrimSpace(line), fence):
+			flush()
+			end := i + 1
+			for end < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[end]), fence) {
+				end++
+			}
+			blocks = append(blocks, preformatted(f.Map(lines[i+1:end], escape))...)
+			i = end + 1
+
+		case heading.MatchString(line):
+			flush()
+			blocks = append(blocks, header(heading.FindStringSubmatch(line)[1]))
+			i++
+
+		case thematicBreak.MatchString(line):
+			flush()
+			blocks = append(blocks, block{Block: slack.NewDividerBlock(), text: "---"})
+			i++
+
+		// Table, starting from the header row right before the divider row
+		case strings.Contains(line, "|") && i+1 < len(lines) && tableDivider.MatchString(lines[i+1]):
+			flush()
+			end := i + 2
+			for end < len(lines) && strings.Contains(lines[end], "|") {
+				end++
+			}
+			blocks = append(blocks, preformatted(table(lines[i], lines[i+1], lines[i+2:end]))...)
+			i = end
+
+		default:
+			paragraph = append(paragraph, convertLine(line))
+			i++
+		}
+	}
+	flush()
+
+	return blocks
+}
+
+// section is a block of mrkdwn
+func section(text string) block {
+	return block{
+		Block: slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, text, false, false), nil, nil),
+		text:  text,
+	}
+}
+
+// header is a block of a heading, which is a bold section if too long for a header
+func header(text string) block {
+	title := plain(text)
+	if title == "" || utf8.RuneCountInString(title) > maxHeaderText {
+		return section("*" + strings.ReplaceAll(convertInline(text), "*", "") + "*")
+	}
+	return block{
+		Block: slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, title, false, false)),
+		text:  "*" + escape(title) + "*",
+	}
+}
+
+// preformatted are the blocks of monospaced lines (already escaped), split into more than one if too long for one
+func preformatted(lines []string) []block {
+	if len(lines) == 0 {
+		return nil
+	}
+	// Leave room for the fences around each chunk
+	return f.Map(Split(strings.Join(lines, "\n"), MaxSectionText-2*(len(fence)+1)), func(chunk string) block {
+		return section(fence + "\n" + chunk + "\n" + fence)
+	})
+}
+
+// table lays out the rows of a table in aligned columns, as Slack has no tables
+func table(head string, divider string, rows []string) []string {
+	cells := func(row string) []string {
+		row = strings.TrimSpace(row)
+		row = strings.TrimSuffix(strings.TrimPrefix(row, "|"), "|")
+		return f.Map(strings.Split(row, "|"), func(cell string) string { return plain(strings.TrimSpace(cell)) })
+	}
+
+	grid := append([][]string{cells(head)}, f.Map(rows, cells)...)
+	right := f.Map(cells(divider), func(cell string) bool { return strings.HasSuffix(cell, ":") && !strings.HasPrefix(cell, ":") })
+
+	widths := []int{}
+	for _, row := range grid {
+		for j, cell := range row {
+			if j >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[j] = f.IfElse(utf8.RuneCountInString(cell) > widths[j], utf8.RuneCountInString(cell), widths[j])
+		}
+	}
+
+	layout := func(row []string) string {
+		columns := make([]string, len(widths))
+		for j, width := range widths {
+			cell := ""
+			if j < len(row) {
+				cell = row[j]
+			}
+			padding := strings.Repeat(" ", width-utf8.RuneCountInString(cell))
+			columns[j] = f.IfElse(j < len(right) && right[j], padding+cell, cell+padding)
+		}
+		return escape(strings.TrimRight(strings.Join(columns, " | "), " "))
+	}
+
+	lines := []string{layout(grid[0])}
+	lines = append(lines, strings.Join(f.Map(widths, func(width int) string { return strings.Repeat("-", width) }), "-+-"))
+	return append(lines, f.Map(grid[1:], layout)...)
+}
+
+// plain strips the Markdown of the text, for where Slack shows it as is (e.g. headers, tables)
+func plain(text string) string {
+	text = image.ReplaceAllString(text, "$1")
+	text = link.ReplaceAllString(text, "$1")
+	text = boldItalic.ReplaceAllString(text, "$1")
+	text = bounded(underscoreBoldItalic, text, "$1")
+	text = bold.ReplaceAllString(text, "$1")
+	text = bounded(underscoreBold, text, "$1")
+	text = italic.ReplaceAllString(text, "$1")
+	text = strikethrough.ReplaceAllString(text, "$1")
+	return strings.TrimSpace(strings.ReplaceAll(text, "`", ""))
+}
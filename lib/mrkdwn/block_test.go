@@ -0,0 +1,91 @@
+package mrkdwn
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+// kinds names the type of every block, so a test failure shows what the Markdown became
+func kinds(messages []Message) [][]string {
+	res := [][]string{}
+	for _, message := range messages {
+		types := []string{}
+		for _, b := range message.Blocks {
+			types = append(types, string(b.BlockType()))
+		}
+		res = append(res, types)
+	}
+	return res
+}
+
+func TestMessages(t *testing.T) {
+	paragraph := strings.Repeat("word ", 700) + "\n"
+
+	tests := []struct {
+		name     string
+		markdown string
+		blocks   []int
+	}{
+		{name: "empty", markdown: "", blocks: []int{}},
+		{name: "paragraphs", markdown: "one\n\ntwo", blocks: []int{1}},
+		{name: "heading and divider", markdown: "# Title\ntext\n\n---\nmore", blocks: []int{4}},
+		{name: "code block", markdown: "look:\n```go\nfmt.Println(1 < 2)\n```\ndone", blocks: []int{3}},
+		{name: "unclosed code block", markdown: "```\nstill streaming", blocks: []int{1}},
+		{name: "table", markdown: "| a | b |\n|---|--:|\n| 1 | 22 |", blocks: []int{1}},
+		{name: "long paragraph", markdown: strings.Repeat(paragraph, 2), blocks: []int{4}},
+		{name: "too many blocks", markdown: strings.Repeat("# Title\n", maxBlocks+5), blocks: []int{maxBlocks, 5}},
+		{name: "too much text", markdown: strings.Repeat(paragraph+"\n---\n", 12), blocks: []int{33, 3}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages := Messages(test.markdown)
+			if len(messages) != len(test.blocks) {
+				t.Fatalf("expected %d message(s), got %v", len(test.blocks), kinds(messages))
+			}
+
+			for i, message := range messages {
+				if len(message.Blocks) != test.blocks[i] {
+					t.Fatalf("expected %v blocks, got %v", test.blocks, kinds(messages))
+				}
+				if n := utf8.RuneCountInString(message.Text); n > maxMessageText {
+					t.Fatalf("message %d has %d characters", i, n)
+				}
+				for j, b := range message.Blocks {
+					section, ok := b.(*slack.SectionBlock)
+					if !ok {
+						continue
+					}
+					if n := utf8.RuneCountInString(section.Text.Text); n > MaxSectionText {
+						t.Fatalf("section %d of message %d has %d characters", j, i, n)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestMessagesText(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		text     string
+	}{
+		{name: "inline", markdown: "**Hi** <@U012AB3CD>, see [this](https://a.b)", text: "*Hi* <@U012AB3CD>, see <https://a.b|this>"},
+		{name: "header", markdown: "# The __plan__ & *more*", text: "*The plan &amp; more*"},
+		{name: "code block", markdown: "```\nif a < b {}\n```", text: "```\nif a &lt; b {}\n```"},
+		{name: "table", markdown: "| name | n |\n|:--|--:|\n| **a** | 1 |\n| bb | 22 |", text: "```\nname |  n\n-----+---\na    |  1\nbb   | 22\n```"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			messages := Messages(test.markdown)
+			if len(messages) != 1 || messages[0].Text != test.text {
+				t.Fatalf("expected %q, got %+v", test.text, messages)
+			}
+		})
+	}
+}
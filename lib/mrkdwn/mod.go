@@ -0,0 +1,161 @@
+package mrkdwn
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"d-exclaimation.me/relax/lib/f"
+)
+
+const fence = "```"
+
+var (
+	// special matches what Slack uses for mentions and links (kept as is), or the characters it needs escaped
+	special = regexp.MustCompile(`<[@#!][^<>\s]*>|<https?://[^<>\s]*>|[&<>]`)
+
+	heading       = regexp.MustCompile(`^\s{0,3}#{1,6}\s+(.+?)\s*#*\s*$`)
+	quote         = regexp.MustCompile(`^\s{0,3}>\s?`)
+	bullet        = regexp.MustCompile(`^(\s*)[-*+]\s+`)
+	image         = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	link          = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	boldItalic    = regexp.MustCompile(`\*\*\*(\S(?:.*?\S)??)\*\*\*`)
+	bold          = regexp.MustCompile(`\*\*(\S(?:.*?\S)??)\*\*`)
+	italic        = regexp.MustCompile(`\*([^\s*](?:.*?[^\s*])??)\*`)
+	strikethrough = regexp.MustCompile(`~~(\S(?:.*?\S)??)~~`)
+
+	// Underscores only mark emphasis outside of a word (e.g. not in `snake__case`)
+	underscoreBoldItalic = regexp.MustCompile(`___(\S(?:.*?\S)??)___`)
+	underscoreBold       = regexp.MustCompile(`__(\S(?:.*?\S)??)__`)
+)
+
+// convertLine converts a single line outside of a code block
+func convertLine(line string) string {
+	if match := heading.FindStringSubmatch(line); match != nil {
+		return "*" + strings.ReplaceAll(convertInline(match[1]), "*", "") + "*"
+	}
+
+	// Slack only knows a single level of quote
+	if match := quote.FindString(line); match != "" {
+		return "> " + convertLine(strings.TrimLeft(line[len(match):], "> "))
+	}
+
+	indent := ""
+	if match := bullet.FindStringSubmatch(line); match != nil {
+		indent = match[1] + "• "
+		line = line[len(match[0]):]
+	}
+	return indent + convertInline(line)
+}
+
+// convertInline converts the emphasis and links of the text, leaving the inline code (between backticks) as is
+func convertInline(text string) string {
+	parts := strings.Split(text, "`")
+	for i := range parts {
+		// Odd parts are inside backticks, except for the last one if the backtick is never closed
+		if i%2 == 1 && i < len(parts)-1 {
+			parts[i] = escape(parts[i])
+			continue
+		}
+
+		part := escape(parts[i])
+		part = image.ReplaceAllString(part, "<$2|$1>")
+		part = link.ReplaceAllString(part, "<$2|$1>")
+
+		// Bold is marked with a placeholder so it is not mistaken for italic
+		part = boldItalic.ReplaceAllString(part, "\x00_${1}_\x00")
+		part = bounded(underscoreBoldItalic, part, "\x00_${1}_\x00")
+		part = bold.ReplaceAllString(part, "\x00$1\x00")
+		part = bounded(underscoreBold, part, "\x00$1\x00")
+		part = italic.ReplaceAllString(part, "_${1}_")
+		part = strings.ReplaceAll(part, "\x00", "*")
+		part = strikethrough.ReplaceAllString(part, "~$1~")
+		parts[i] = part
+	}
+	return strings.Join(parts, "`")
+}
+
+// wordy returns true for the characters that make up a word
+func wordy(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// bounded replaces the matches with the template (as in regexp.Expand), only where they are not inside a word
+func bounded(re *regexp.Regexp, text string, template string) string {
+	res := []byte{}
+	i := 0
+	for i < len(text) {
+		match := re.FindStringSubmatchIndex(text[i:])
+		if match == nil {
+			break
+		}
+		start, end := i+match[0], i+match[1]
+		before, _ := utf8.DecodeLastRuneInString(text[:start])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+
+		// Inside a word, so try again from the next character
+		if (start > 0 && wordy(before)) || (end < len(text) && wordy(after)) {
+			_, size := utf8.DecodeRuneInString(text[start:])
+			res = append(res, text[i:start+size]...)
+			i = start + size
+			continue
+		}
+
+		res = append(res, text[i:start]...)
+		res = re.ExpandString(res, template, text[i:], match)
+		i = end
+	}
+	return string(append(res, text[i:]...))
+}
+
+// escape escapes the characters Slack uses for its own markup, besides the mentions and links
+func escape(text string) string {
+	return special.ReplaceAllStringFunc(text, func(s string) string {
+		switch s {
+		case "&":
+			return "&amp;"
+		case "<":
+			return "&lt;"
+		case ">":
+			return "&gt;"
+		}
+		return s
+	})
+}
+
+// Split breaks the text into chunks of at most size characters, preferably at the end of a line, where a code block
+// cut in between is closed at the end of the chunk and reopened in the next
+func Split(text string, size int) []string {
+	chunks := []string{}
+	open := false
+	for runes := []rune(text); len(runes) > 0; {
+		// Leave room for the code block to be reopened and closed
+		limit := size - 2*(len(fence)+1)
+		if len(runes) <= size && !open {
+			chunks = append(chunks, string(runes))
+			break
+		}
+		if len(runes) <= limit {
+			chunks = append(chunks, reopen(open)+string(runes))
+			break
+		}
+
+		cut := limit
+		if i := strings.LastIndex(string(runes[:limit]), "\n"); i > 0 {
+			cut = len([]rune(string(runes[:limit])[:i]))
+		}
+
+		chunk := string(runes[:cut])
+		wasOpen := open
+		open = open != (strings.Count(chunk, fence)%2 == 1)
+		chunks = append(chunks, reopen(wasOpen)+chunk+f.IfElse(open, "\n"+fence, ""))
+		runes = []rune(strings.TrimPrefix(string(runes[cut:]), "\n"))
+	}
+	return chunks
+}
+
+// reopen gives the start of a code block if the previous chunk ended in one
+func reopen(open bool) string {
+	return f.IfElse(open, fence+"\n", "")
+}
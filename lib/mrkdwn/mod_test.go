@@ -0,0 +1,88 @@
+package mrkdwn
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestConvertLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		mrkdwn   string
+	}{
+		{name: "bold", markdown: "**bold** and __bold__", mrkdwn: "*bold* and *bold*"},
+		{name: "italic", markdown: "*italic* and _italic_", mrkdwn: "_italic_ and _italic_"},
+		{name: "bold italic", markdown: "***both*** and ___both___", mrkdwn: "*_both_* and *_both_*"},
+		{name: "italic inside bold", markdown: "**bold _and_ italic**", mrkdwn: "*bold _and_ italic*"},
+		{name: "several on a line", markdown: "**a** then **b**, __c__ then __d__", mrkdwn: "*a* then *b*, *c* then *d*"},
+		{name: "strikethrough", markdown: "~~gone~~ ~~twice~~", mrkdwn: "~gone~ ~twice~"},
+		{name: "underscores inside words", markdown: "snake__case__name and my__init__", mrkdwn: "snake__case__name and my__init__"},
+		{name: "single underscores", markdown: "snake_case_name", mrkdwn: "snake_case_name"},
+		{name: "not emphasis", markdown: "2 * 3 * 4 and ** spaced **", mrkdwn: "2 * 3 * 4 and ** spaced **"},
+		{name: "inline code", markdown: "`**not bold** <b>` but **bold**", mrkdwn: "`**not bold** &lt;b&gt;` but *bold*"},
+		{name: "unclosed code", markdown: "a `b **c**", mrkdwn: "a `b *c*"},
+		{name: "link", markdown: "see [the docs](https://api.slack.com)", mrkdwn: "see <https://api.slack.com|the docs>"},
+		{name: "image", markdown: `![logo](https://a.b/c.png "title")`, mrkdwn: "<https://a.b/c.png|logo>"},
+		{name: "escaped", markdown: "a < b && c > d", mrkdwn: "a &lt; b &amp;&amp; c &gt; d"},
+		{name: "mentions", markdown: "hi <@U012AB3CD> in <#C012AB3CD|general> <!here> <https://a.b>", mrkdwn: "hi <@U012AB3CD> in <#C012AB3CD|general> <!here> <https://a.b>"},
+		{name: "heading", markdown: "## A **big** title ##", mrkdwn: "*A big title*"},
+		{name: "quote", markdown: ">> nested **quote**", mrkdwn: "> nested *quote*"},
+		{name: "bullet", markdown: "  - item *one*", mrkdwn: "  • item _one_"},
+		{name: "bullet with a star", markdown: "* item", mrkdwn: "• item"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if res := convertLine(test.markdown); res != test.mrkdwn {
+				t.Fatalf("expected %q, got %q", test.mrkdwn, res)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	line := strings.Repeat("a", 99) + "\n"
+	code := fence + "\n" + strings.Repeat(line, 40) + fence
+
+	tests := []struct {
+		name   string
+		text   string
+		chunks int
+	}{
+		{name: "short", text: "hello", chunks: 1},
+		{name: "exactly the size", text: strings.Repeat("a", MaxSectionText), chunks: 1},
+		{name: "one over", text: strings.Repeat("a", MaxSectionText+1), chunks: 2},
+		{name: "lines", text: strings.Repeat(line, 70), chunks: 3},
+		{name: "multi-byte", text: strings.Repeat("é", MaxSectionText+10), chunks: 2},
+		{name: "code block cut in between", text: "before\n" + code + "\nafter", chunks: 2},
+		{name: "long code block", text: strings.Repeat(code+"\n", 3), chunks: 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chunks := Split(test.text, MaxSectionText)
+			if len(chunks) != test.chunks {
+				t.Fatalf("expected %d chunks, got %d", test.chunks, len(chunks))
+			}
+
+			joined := ""
+			for i, chunk := range chunks {
+				if n := utf8.RuneCountInString(chunk); n > MaxSectionText {
+					t.Fatalf("chunk %d has %d characters", i, n)
+				}
+				if strings.Count(chunk, fence)%2 != 0 {
+					t.Fatalf("chunk %d leaves a code block open", i)
+				}
+				joined += chunk
+			}
+
+			// Nothing is lost besides the line breaks cut at, and the fences added around the cuts
+			strip := func(s string) string { return strings.NewReplacer("\n", "", fence, "").Replace(s) }
+			if strip(joined) != strip(test.text) {
+				t.Fatal("the chunks do not add up to the text")
+			}
+		})
+	}
+}
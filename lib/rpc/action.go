@@ -2,42 +2,158 @@ package rpc
 
 import (
 	"log"
+	"regexp"
 	"strings"
 
 	"d-exclaimation.me/relax/lib/async"
 	"d-exclaimation.me/relax/lib/f"
+	"github.com/slack-go/slack"
 )
 
-type Resolver[C any] func(args string, ctx C) error
+type Resolver[C any] func(args Args, ctx C) error
 
 type Handler[C any] func(string, func() C) error
 
+// Responder posts messages made by the router itself (e.g. help and errors) back to whoever invoked the action
+type Responder[C any] func(ctx C, msg ...slack.MsgOption) error
+
 type Action[C any] struct {
-	resolver Resolver[C]
-	trigger  func(string) bool
+	name        string
+	aliases     []string
+	description string
+	syntax      string
+	resolver    Resolver[C]
+	trigger     trigger
+	tier        int
+	priority    int
+	params      []Param
+	router      *ActionsRouter[C]
+
+	// patterns are the compiled patterns of a Regex action and its aliases
+	patterns map[string]*regexp.Regexp
 }
 
+// Exact creates an action triggered when the first word is the path
 func Exact[C any](path string, resolver Resolver[C]) Action[C] {
 	return Action[C]{
+		name:     path,
+		resolver: resolver,
+		trigger:  exactly,
+		tier:     exactTier,
+	}
+}
+
+// Prefix creates an action triggered when the first word starts with the path,
+// where the rest of the word is given as the `suffix` param
+func Prefix[C any](path string, resolver Resolver[C]) Action[C] {
+	return Action[C]{
+		name:     path,
 		resolver: resolver,
-		trigger: func(event string) bool {
-			return strings.ToLower(strings.TrimSpace(event)) == path
-		},
+		trigger:  prefixed,
+		tier:     prefixTier,
 	}
 }
 
+// Regex creates an action triggered when the message matches the pattern,
+// where every captured group is given as a param by its name (or index if unnamed)
+func Regex[C any](pattern string, resolver Resolver[C]) Action[C] {
+	patterns := map[string]*regexp.Regexp{pattern: regexp.MustCompile(pattern)}
+	return Action[C]{
+		name:     pattern,
+		resolver: resolver,
+		trigger:  matching(patterns),
+		tier:     regexTier,
+		patterns: patterns,
+	}
+}
+
+// Contains creates an action triggered when the first word contains the path anywhere
 func Contains[C any](path string, resolver Resolver[C]) Action[C] {
 	return Action[C]{
+		name:     path,
 		resolver: resolver,
-		trigger: func(event string) bool {
-			return strings.Contains(strings.ToLower(event), path)
-		},
+		trigger:  containing,
+		tier:     containsTier,
+	}
+}
+
+// Params sets the arguments the action accepts, which are parsed and validated before the resolver runs
+func (a Action[C]) Params(params ...Param) Action[C] {
+	a.params = params
+	return a
+}
+
+// Alias adds other names the action can be triggered with, which are patterns for a Regex action
+// (panicking on an invalid one, like Regex itself)
+func (a Action[C]) Alias(names ...string) Action[C] {
+	a.aliases = append(append([]string{}, a.aliases...), names...)
+	if a.patterns != nil {
+		patterns := make(map[string]*regexp.Regexp, len(a.patterns)+len(names))
+		for pattern, re := range a.patterns {
+			patterns[pattern] = re
+		}
+		for _, name := range names {
+			patterns[name] = regexp.MustCompile(name)
+		}
+		a.patterns = patterns
+		a.trigger = matching(patterns)
+	}
+	return a
+}
+
+// Describe sets the description shown in the help message
+func (a Action[C]) Describe(description string) Action[C] {
+	a.description = description
+	return a
+}
+
+// Syntax overrides the generated syntax shown in the help and usage messages
+func (a Action[C]) Syntax(syntax string) Action[C] {
+	a.syntax = syntax
+	return a
+}
+
+// Usage returns the syntax for the action
+func (a Action[C]) Usage() string {
+	return a.usageAt(nil)
+}
+
+// usageAt returns the syntax for the action when mounted under a command path
+func (a Action[C]) usageAt(path []string) string {
+	command := strings.Join(join(path, a.name), " ")
+	switch {
+	case a.syntax != "":
+		return strings.TrimSpace(strings.Join(path, " ") + " " + a.syntax)
+	case a.router != nil:
+		return command + " <command>"
+	}
+	return usage(command, a.params)
+}
+
+// Priority overrides the order between actions matching the same message, where a higher priority wins
+// (otherwise Exact wins over Prefix, then Regex, then Contains, with ties going to the more specific match)
+func (a Action[C]) Priority(priority int) Action[C] {
+	a.priority = priority
+	return a
+}
+
+// Mount creates an action that hands the rest of the message to another router, so commands can be grouped in a namespace
+func Mount[C any](path string, router ActionsRouter[C]) Action[C] {
+	return Action[C]{
+		name:    path,
+		router:  &router,
+		trigger: exactly,
+		tier:    exactTier,
 	}
 }
 
 type ActionsRouter[C any] struct {
-	actions  []Action[C]
-	fallback Resolver[C]
+	actions     []Action[C]
+	fallback    *Action[C]
+	responder   Responder[C]
+	presenter   Presenter
+	middlewares []Middleware[C]
+	roots       []string
 }
 
 func Actions[C any](routes ...Action[C]) ActionsRouter[C] {
@@ -46,57 +162,224 @@ func Actions[C any](routes ...Action[C]) ActionsRouter[C] {
 	}
 }
 
-func (r ActionsRouter[C]) Else(resolver Resolver[C]) ActionsRouter[C] {
-	r.fallback = resolver
+// Else sets the resolver used when no action matches, optionally with the params it accepts
+func (r ActionsRouter[C]) Else(resolver Resolver[C], params ...Param) ActionsRouter[C] {
+	r.fallback = &Action[C]{
+		resolver: resolver,
+		params:   f.IfElse(len(params) > 0, params, nil),
+	}
 	return r
 }
 
-func (r *ActionsRouter[C]) HandleMentionAsync(message string, ctx func() C) async.Task[async.Unit] {
-	return async.New(func() (async.Unit, error) {
-		args := f.Filter(strings.Split(strings.TrimSpace(message), " "), func(word string) bool {
-			return !strings.HasPrefix(word, "<@") && !strings.HasSuffix(word, ">")
-		})
+// Root sets the slash commands that route their text to the router (e.g. `/relax <command>`)
+func (r ActionsRouter[C]) Root(commands ...string) ActionsRouter[C] {
+	r.roots = append(r.roots, f.Map(commands, func(command string) string {
+		return strings.ToLower(strings.TrimPrefix(command, "/"))
+	})...)
+	return r
+}
 
-		if len(args) < 1 {
-			return async.Done, nil
-		}
+// Respond sets how the router posts its own messages back to the user
+func (r ActionsRouter[C]) Respond(responder Responder[C]) ActionsRouter[C] {
+	r.responder = responder
+	return r
+}
 
-		event := args[0]
+// Catch sets how errors given back by the resolvers are shown to the user, instead of the DefaultPresenter
+func (r ActionsRouter[C]) Catch(presenter Presenter) ActionsRouter[C] {
+	r.presenter = presenter
+	return r
+}
 
-		for _, route := range r.actions {
-			if route.trigger(event) {
-				err := route.resolver(strings.Join(args[1:], " "), ctx())
-				if err != nil {
-					log.Printf("%s gives back %s\n", event, err.Error())
-				}
-				return async.Done, nil
-			}
+// Use adds middlewares that wrap every resolver in the router, including the ones in mounted routers
+func (r ActionsRouter[C]) Use(middlewares ...Middleware[C]) ActionsRouter[C] {
+	r.middlewares = append(r.middlewares, middlewares...)
+	return r
+}
+
+// scope is what a router passes down to the routers mounted under it
+type scope[C any] struct {
+	path        []string
+	responder   Responder[C]
+	presenter   Presenter
+	middlewares []Middleware[C]
+
+	// literal skips the typo suggestions, when the user already chose to ignore them
+	literal bool
+}
+
+// enter returns the scope inside the router, with the router's own settings taking over
+func (s scope[C]) enter(r *ActionsRouter[C]) scope[C] {
+	if r.responder != nil {
+		s.responder = r.responder
+	}
+	if r.presenter != nil {
+		s.presenter = r.presenter
+	}
+	s.middlewares = append(append([]Middleware[C]{}, s.middlewares...), r.middlewares...)
+	return s
+}
+
+func (r *ActionsRouter[C]) HandleMentionAsync(message string, ctx func() C) async.Task[async.Unit] {
+	return async.New(func() (async.Unit, error) {
+		words := tokenize(message)
+
+		// Ignore the mentions before the command (usually the bot itself)
+		for len(words) > 0 && userMention.MatchString(words[0].value) {
+			words = words[1:]
 		}
 
-		if r.fallback != nil {
-			err := r.fallback(strings.Join(args, " "), ctx())
-			if err != nil {
-				log.Printf("fallback gives back %s\n", err.Error())
-			}
+		if len(words) < 1 {
+			return async.Done, nil
 		}
 
+		r.dispatch(scope[C]{}, message[words[0].start:], ctx)
 		return async.Done, nil
 	})
 }
 
-func (r *ActionsRouter[C]) HandleCommandAsync(command string, ctx func() C) async.Task[async.Unit] {
+// HandleCommandAsync handles a slash command, where the command name is either one of the root commands
+// (e.g. `/relax reviewer --count 2`) or the name of an action itself (e.g. `/reviewer --count 2`)
+func (r *ActionsRouter[C]) HandleCommandAsync(command string, text string, ctx func() C) async.Task[async.Unit] {
 	return async.New(func() (async.Unit, error) {
-		args := strings.Split(strings.TrimSpace(f.TailString(command)), " ")
-		event := args[0]
-		for _, route := range r.actions {
-			if route.trigger(event) {
-				err := route.resolver(strings.Join(args[1:], " "), ctx())
+		name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(command), "/"))
+		text = strings.TrimSpace(text)
+
+		// Commands named after an action are routed as if the name was the first word
+		if _, ok := r.route(name, tokenize(name)); ok && !f.IsMember(r.roots, name) {
+			text = strings.TrimSpace(name + " " + text)
+		}
+
+		if text == "" {
+			if r.responder != nil {
+				err := r.responder(ctx(), r.helpMessage(nil, nil))
 				if err != nil {
-					log.Printf("%s gives back %s\n", event, err.Error())
+					log.Printf("/%s gives back %s\n", name, err.Error())
 				}
-				return async.Done, nil
 			}
+			return async.Done, nil
 		}
+
+		r.dispatch(scope[C]{}, text, ctx)
 		return async.Done, nil
 	})
 }
+
+// dispatch finds the action that best matches the text and runs it with the rest as arguments,
+// going down into mounted routers with the path so far
+func (r *ActionsRouter[C]) dispatch(parent scope[C], text string, ctx func() C) {
+	s := parent.enter(r)
+
+	text = strings.TrimSpace(text)
+	words := tokenize(text)
+	values := f.Map(words, func(t token) string { return t.value })
+
+	if route, ok := r.route(text, words); ok {
+		rest := strings.TrimSpace(text[route.hit.offset:])
+
+		if route.action.router != nil {
+			inner := s
+			inner.path = join(s.path, route.action.name)
+			route.action.router.dispatch(inner, rest, ctx)
+			return
+		}
+
+		run(s, route.action, rest, route.hit.captures, ctx)
+		return
+	}
+
+	if len(words) > 0 && strings.ToLower(words[0].value) == "help" && s.responder != nil {
+		err := s.responder(ctx(), r.helpMessage(s.path, values[1:]))
+		if err != nil {
+			log.Printf("help gives back %s\n", err.Error())
+		}
+		return
+	}
+
+	// Typos get a suggestion instead of going to the fallback, but only if the rest of the message fits the suggested
+	// command, otherwise it is more likely meant for the fallback (e.g. a question for the AI)
+	if len(words) > 0 && !s.literal && s.responder != nil && len(text) < maxButtonValue {
+		suggestion, ok := r.suggest(words[0].value)
+		if ok && r.fallback != nil {
+			ok = r.fits(suggestion, strings.TrimSpace(text[rest(text, words):]))
+		}
+		if ok {
+			original := strings.Join(join(s.path, text), " ")
+			corrected := strings.Join(join(join(s.path, suggestion), strings.TrimSpace(text[rest(text, words):])), " ")
+			err := s.responder(ctx(), suggestionMessage(
+				strings.Join(join(s.path, suggestion), " "),
+				corrected,
+				original,
+				r.fallback != nil,
+			))
+			if err != nil {
+				log.Printf("%s gives back %s\n", words[0].value, err.Error())
+			}
+			return
+		}
+	}
+
+	if r.fallback != nil {
+		run(s, *r.fallback, text, nil, ctx)
+		return
+	}
+
+	// Mounted routers without a fallback show what they can do instead
+	if len(s.path) > 0 && s.responder != nil {
+		err := s.responder(ctx(), r.helpMessage(s.path, nil))
+		if err != nil {
+			log.Printf("%s gives back %s\n", strings.Join(s.path, " "), err.Error())
+		}
+	}
+}
+
+// run parses the arguments for the action and runs its resolver through the middlewares
+func run[C any](s scope[C], route Action[C], text string, captures map[string]string, ctx func() C) {
+	command := strings.Join(join(s.path, route.name), " ")
+	words := f.Map(tokenize(text), func(t token) string { return t.value })
+
+	c := ctx()
+	args, err := parse(command, text, words, route.params, captures)
+	if err == nil {
+		err = chain(s.middlewares, route.resolver)(args, c)
+	} else if invalid, ok := err.(*UsageError); ok {
+		invalid.Usage = route.usageAt(s.path)
+	}
+
+	if err != nil {
+		s.fail(c, args, err)
+	}
+}
+
+// fail logs the error with a correlation ID and presents it back to the user if possible
+func (s scope[C]) fail(c C, args Args, err error) {
+	failure := Failure{
+		ID:      correlationID(),
+		Command: args.Command(),
+		Err:     err,
+	}
+	log.Printf("[%s] %s gives back %s\n", failure.ID, args.label(), err.Error())
+
+	if s.responder == nil {
+		return
+	}
+
+	presenter := f.IfElse(s.presenter != nil, s.presenter, DefaultPresenter)
+	err = s.responder(c,
+		slack.MsgOptionText(failure.Text(), false),
+		slack.MsgOptionBlocks(presenter(failure)...),
+	)
+	if err != nil {
+		log.Printf("[%s] %s failed to present the error, %s\n", failure.ID, args.label(), err.Error())
+	}
+}
+
+// join appends a name to a command path without modifying the original
+func join(path []string, name string) []string {
+	res := make([]string, 0, len(path)+1)
+	res = append(res, path...)
+	if name != "" {
+		res = append(res, name)
+	}
+	return res
+}
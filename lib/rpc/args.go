@@ -0,0 +1,420 @@
+package rpc
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"d-exclaimation.me/relax/lib/f"
+)
+
+// Kind is the type of value an argument accepts
+type Kind int
+
+const (
+	// String accepts any word or quoted string
+	String Kind = iota
+
+	// Int accepts a whole number
+	Int
+
+	// Bool accepts true / false, or nothing at all when used as a flag
+	Bool
+
+	// User accepts a user mention (e.g. <@U012AB3CD>) and gives back the user ID
+	User
+
+	// Channel accepts a channel mention (e.g. <#C012AB3CD|general>) and gives back the channel ID
+	Channel
+)
+
+var (
+	userMention    = regexp.MustCompile(`^<@([UW][A-Z0-9]+)(\|[^>]*)?>$`)
+	channelMention = regexp.MustCompile(`^<#(C[A-Z0-9]+)(\|[^>]*)?>$`)
+)
+
+// placeholder is how the kind is shown in a usage message
+func (k Kind) placeholder() string {
+	switch k {
+	case Int:
+		return "number"
+	case Bool:
+		return "true|false"
+	case User:
+		return "@user"
+	case Channel:
+		return "#channel"
+	default:
+		return "text"
+	}
+}
+
+// normalize validates a raw value and converts it to its canonical form
+func (k Kind) normalize(value string) (string, error) {
+	switch k {
+	case Int:
+		if _, err := strconv.Atoi(value); err != nil {
+			return "", fmt.Errorf("`%s` is not a number", value)
+		}
+	case Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", fmt.Errorf("`%s` is not true or false", value)
+		}
+		return strconv.FormatBool(b), nil
+	case User:
+		matches := userMention.FindStringSubmatch(value)
+		if matches == nil {
+			return "", fmt.Errorf("`%s` is not a user mention", value)
+		}
+		return matches[1], nil
+	case Channel:
+		matches := channelMention.FindStringSubmatch(value)
+		if matches == nil {
+			return "", fmt.Errorf("`%s` is not a channel mention", value)
+		}
+		return matches[1], nil
+	}
+	return value, nil
+}
+
+// Param is a single positional argument or named flag an action accepts
+type Param struct {
+	name     string
+	kind     Kind
+	flag     bool
+	required bool
+	variadic bool
+	rest     bool
+	fallback *string
+}
+
+// Arg creates a positional argument, matched in the order it is declared
+func Arg(name string, kind Kind) Param {
+	return Param{name: name, kind: kind}
+}
+
+// Flag creates a named flag, given as `--name value` or `--name=value`
+func Flag(name string, kind Kind) Param {
+	return Param{name: name, kind: kind, flag: true}
+}
+
+// Required marks the param as mandatory
+func (p Param) Required() Param {
+	p.required = true
+	return p
+}
+
+// Variadic allows the param to be given multiple times (or to take every remaining word if positional)
+func (p Param) Variadic() Param {
+	p.variadic = true
+	return p
+}
+
+// Rest makes the positional param take the rest of the message as written (e.g. a question), starting from its first word
+// or after a bare `--`, where nothing in it is parsed as a flag
+func (p Param) Rest() Param {
+	p.rest = true
+	return p
+}
+
+// Default sets the value used when the param is not given
+func (p Param) Default(value string) Param {
+	p.fallback = &value
+	return p
+}
+
+// usage is how the param is shown in a usage message
+func (p Param) usage() string {
+	res := ""
+	switch {
+	case p.flag && p.kind == Bool:
+		res = fmt.Sprintf("--%s", p.name)
+	case p.flag:
+		res = fmt.Sprintf("--%s <%s>", p.name, p.kind.placeholder())
+	default:
+		res = fmt.Sprintf("<%s>", p.name)
+	}
+	if !p.required {
+		res = fmt.Sprintf("[%s]", res)
+	}
+	if p.variadic || p.rest {
+		res += "..."
+	}
+	return res
+}
+
+// Args are the arguments given to a resolver, parsed and validated against the action's params
+type Args struct {
+	command     string
+	text        string
+	positionals []string
+	values      map[string][]string
+}
+
+// Command returns the full name of the action invoked (empty for a top level fallback)
+func (a Args) Command() string {
+	return a.command
+}
+
+// label is how the action invoked is named in logs
+func (a Args) label() string {
+	return f.IfElse(a.command != "", a.command, "fallback")
+}
+
+// Text returns the arguments as the user wrote them
+func (a Args) Text() string {
+	return a.text
+}
+
+// Positionals returns every word that was not consumed as a flag
+func (a Args) Positionals() []string {
+	return a.positionals
+}
+
+// Has returns true if the param was given or has a default
+func (a Args) Has(name string) bool {
+	return len(a.values[name]) > 0
+}
+
+// String returns the value of a param, or an empty string if not given
+func (a Args) String(name string) string {
+	values := a.values[name]
+	if len(values) < 1 {
+		return ""
+	}
+	return values[0]
+}
+
+// Strings returns every value given to a variadic param
+func (a Args) Strings(name string) []string {
+	return a.values[name]
+}
+
+// Int returns the value of an Int param, or 0 if not given
+func (a Args) Int(name string) int {
+	return f.ParseInt(a.String(name))
+}
+
+// Bool returns the value of a Bool param, or false if not given
+func (a Args) Bool(name string) bool {
+	return a.String(name) == "true"
+}
+
+// UsageError is the error given back when the arguments do not match the action's params
+type UsageError struct {
+	// Command is the name of the action invoked
+	Command string
+
+	// Reason is what is wrong with the arguments
+	Reason string
+
+	// Usage is the generated syntax for the action
+	Usage string
+}
+
+func (e *UsageError) Error() string {
+	return fmt.Sprintf("%s (usage: %s)", e.Reason, e.Usage)
+}
+
+// usage generates the syntax for a command with the given params
+func usage(command string, params []Param) string {
+	parts := f.Filter([]string{command}, func(part string) bool { return part != "" })
+	for _, param := range params {
+		if !param.flag {
+			parts = append(parts, param.usage())
+		}
+	}
+	for _, param := range params {
+		if param.flag {
+			parts = append(parts, param.usage())
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// parse validates the words against the params and returns the parsed arguments, along with the captures
+// of the trigger (e.g. named groups of a Regex action) for the params not given in the words
+func parse(command string, text string, words []string, params []Param, captures map[string]string) (Args, error) {
+	args := Args{
+		command:     command,
+		text:        text,
+		positionals: make([]string, 0),
+		values:      make(map[string][]string),
+	}
+
+	captured := func() {
+		for name, value := range captures {
+			if len(args.values[name]) == 0 {
+				args.values[name] = []string{value}
+			}
+		}
+	}
+
+	if params == nil {
+		args.positionals = words
+		captured()
+		return args, nil
+	}
+
+	fail := func(format string, a ...any) (Args, error) {
+		return args, &UsageError{
+			Command: command,
+			Reason:  fmt.Sprintf(format, a...),
+			Usage:   usage(command, params),
+		}
+	}
+
+	flags := f.Filter(params, func(p Param) bool { return p.flag })
+	positionals := f.Filter(params, func(p Param) bool { return !p.flag })
+
+	// The param taking the rest of the message as written, once the positionals before it are given
+	remainder, index, hasRemainder := Param{}, -1, false
+	if len(positionals) > 0 {
+		remainder, index, hasRemainder = f.FindIndexOf(positionals, func(p Param) bool { return p.rest })
+	}
+	tokens := tokenize(text)
+	raw := func(from int) string {
+		if from >= len(tokens) {
+			return ""
+		}
+		return strings.TrimSpace(text[tokens[from].start:])
+	}
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+
+		// A bare `--` ends the flags, where everything after it is positional
+		if word == "--" {
+			if hasRemainder && len(args.positionals) == index {
+				if rest := raw(i + 1); rest != "" {
+					args.values[remainder.name] = []string{rest}
+				}
+			} else {
+				args.positionals = append(args.positionals, words[i+1:]...)
+			}
+			break
+		}
+
+		if hasRemainder && len(args.positionals) == index && !strings.HasPrefix(word, "--") && !strings.HasPrefix(word, "—") {
+			args.values[remainder.name] = []string{raw(i)}
+			break
+		}
+
+		// Some clients replace `--` with an em dash
+		if strings.HasPrefix(word, "—") {
+			word = "--" + strings.TrimPrefix(word, "—")
+		}
+
+		if !strings.HasPrefix(word, "--") || len(word) <= 2 {
+			args.positionals = append(args.positionals, word)
+			continue
+		}
+
+		name, value, inline := strings.Cut(word[2:], "=")
+		if !f.Some(flags, func(p Param) bool { return p.name == name }) {
+			return fail("Unknown flag `--%s`", name)
+		}
+		flag, _ := f.First(flags, func(p Param) bool { return p.name == name })
+
+		if !inline && flag.kind == Bool {
+			value = "true"
+		} else if !inline {
+			if i+1 >= len(words) {
+				return fail("Flag `--%s` needs a value", name)
+			}
+			i++
+			value = words[i]
+		}
+
+		if !flag.variadic && len(args.values[name]) > 0 {
+			return fail("Flag `--%s` can only be given once", name)
+		}
+
+		normalized, err := flag.kind.normalize(value)
+		if err != nil {
+			return fail("Flag `--%s`: %s", name, err.Error())
+		}
+		args.values[name] = append(args.values[name], normalized)
+	}
+
+	rest := args.positionals
+	for _, param := range f.Filter(positionals, func(p Param) bool { return !p.rest }) {
+		if len(rest) < 1 {
+			break
+		}
+		taken := f.IfElse(param.variadic, rest, rest[:1])
+		for _, value := range taken {
+			normalized, err := param.kind.normalize(value)
+			if err != nil {
+				return fail("Argument `%s`: %s", param.name, err.Error())
+			}
+			args.values[param.name] = append(args.values[param.name], normalized)
+		}
+		rest = rest[len(taken):]
+	}
+
+	if len(rest) > 0 {
+		return fail("Unexpected argument `%s`", rest[0])
+	}
+
+	// Captures count as given, so a required param can come from the trigger
+	captured()
+
+	for _, param := range params {
+		if len(args.values[param.name]) > 0 {
+			continue
+		}
+		if param.required {
+			return fail("Missing %s `%s`", f.IfElse(param.flag, "flag", "argument"), param.name)
+		}
+		if param.fallback != nil {
+			args.values[param.name] = []string{*param.fallback}
+		}
+	}
+
+	return args, nil
+}
+
+// token is a single word in a message along with where it starts
+type token struct {
+	value string
+	start int
+}
+
+// tokenize splits a message into words, keeping double quoted strings together
+func tokenize(message string) []token {
+	tokens := make([]token, 0)
+	current := strings.Builder{}
+	start := -1
+	quoted := false
+
+	flush := func() {
+		if start >= 0 {
+			tokens = append(tokens, token{value: current.String(), start: start})
+		}
+		current.Reset()
+		start = -1
+	}
+
+	for i, r := range message {
+		switch {
+		case r == '"' || r == '“' || r == '”':
+			if start < 0 {
+				start = i
+			}
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\n' || r == '\t'):
+			flush()
+		default:
+			if start < 0 {
+				start = i
+			}
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return tokens
+}
@@ -0,0 +1,224 @@
+package rpc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"d-exclaimation.me/relax/lib/f"
+)
+
+// words splits the text the same way an action does before parsing it
+func words(text string) []string {
+	return f.Map(tokenize(text), func(t token) string { return t.value })
+}
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		values []string
+		starts []int
+	}{
+		{name: "empty", text: "", values: []string{}, starts: []int{}},
+		{name: "words", text: "reviewer  --count 2", values: []string{"reviewer", "--count", "2"}, starts: []int{0, 10, 18}},
+		{name: "quoted", text: `say "hello there" now`, values: []string{"say", "hello there", "now"}, starts: []int{0, 4, 18}},
+		{name: "smart quotes", text: "say “hello there”", values: []string{"say", "hello there"}, starts: []int{0, 4}},
+		{name: "quoted inline flag", text: `--name="a b"`, values: []string{"--name=a b"}, starts: []int{0}},
+		{name: "unclosed quote", text: `say "hello there`, values: []string{"say", "hello there"}, starts: []int{0, 4}},
+		{name: "empty quotes", text: `say ""`, values: []string{"say", ""}, starts: []int{0, 4}},
+		{name: "newlines and tabs", text: "a\nb\tc", values: []string{"a", "b", "c"}, starts: []int{0, 2, 4}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens := tokenize(test.text)
+			values := f.Map(tokens, func(t token) string { return t.value })
+			starts := f.Map(tokens, func(t token) int { return t.start })
+			if !reflect.DeepEqual(values, test.values) || !reflect.DeepEqual(starts, test.starts) {
+				t.Fatalf("expected %q at %v, got %q at %v", test.values, test.starts, values, starts)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	params := []Param{
+		Arg("name", String).Required(),
+		Arg("count", Int).Default("1"),
+		Flag("user", User).Variadic(),
+		Flag("channel", Channel),
+		Flag("force", Bool),
+		Flag("level", Int),
+	}
+
+	tests := []struct {
+		name   string
+		text   string
+		values map[string][]string
+		err    string
+	}{
+		{
+			name:   "positionals with a default",
+			text:   "relax",
+			values: map[string][]string{"name": {"relax"}, "count": {"1"}},
+		},
+		{
+			name:   "every kind",
+			text:   "relax 3 --user <@U012AB3CD> --channel <#C012AB3CD|general> --force --level=2",
+			values: map[string][]string{"name": {"relax"}, "count": {"3"}, "user": {"U012AB3CD"}, "channel": {"C012AB3CD"}, "force": {"true"}, "level": {"2"}},
+		},
+		{
+			name:   "flags before positionals",
+			text:   "--force relax",
+			values: map[string][]string{"name": {"relax"}, "count": {"1"}, "force": {"true"}},
+		},
+		{
+			name:   "variadic flag",
+			text:   "relax --user <@U1> --user <@W2|someone>",
+			values: map[string][]string{"name": {"relax"}, "count": {"1"}, "user": {"U1", "W2"}},
+		},
+		{
+			name:   "inline bool",
+			text:   "relax --force=false",
+			values: map[string][]string{"name": {"relax"}, "count": {"1"}, "force": {"false"}},
+		},
+		{
+			name:   "em dash",
+			text:   "relax —level 4",
+			values: map[string][]string{"name": {"relax"}, "count": {"1"}, "level": {"4"}},
+		},
+		{
+			name:   "quoted value",
+			text:   `"two words" --level "5"`,
+			values: map[string][]string{"name": {"two words"}, "count": {"1"}, "level": {"5"}},
+		},
+		{
+			name:   "bare dashes end the flags",
+			text:   "-- --force",
+			values: map[string][]string{"name": {"--force"}, "count": {"1"}},
+		},
+		{name: "missing argument", text: "--force", err: "Missing argument `name`"},
+		{name: "unknown flag", text: "relax --loud", err: "Unknown flag `--loud`"},
+		{name: "missing value", text: "relax --level", err: "Flag `--level` needs a value"},
+		{name: "flag given twice", text: "relax --level 1 --level 2", err: "Flag `--level` can only be given once"},
+		{name: "not a number", text: "relax many", err: "Argument `count`: `many` is not a number"},
+		{name: "not a user", text: "relax --user bob", err: "Flag `--user`: `bob` is not a user mention"},
+		{name: "not a bool", text: "relax --force=maybe", err: "Flag `--force`: `maybe` is not true or false"},
+		{name: "too many arguments", text: "relax 1 2", err: "Unexpected argument `2`"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, err := parse("test", test.text, words(test.text), params, nil)
+			if test.err != "" {
+				usage, ok := err.(*UsageError)
+				if !ok || usage.Reason != test.err {
+					t.Fatalf("expected %q, got %v", test.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if !reflect.DeepEqual(args.values, test.values) {
+				t.Fatalf("expected %v, got %v", test.values, args.values)
+			}
+		})
+	}
+}
+
+func TestParseRest(t *testing.T) {
+	params := []Param{
+		Arg("message", String).Required().Rest(),
+		Flag("model", String),
+		Flag("precise", Bool),
+	}
+
+	tests := []struct {
+		name    string
+		text    string
+		message string
+		model   string
+		precise bool
+	}{
+		{name: "plain", text: "why is the sky blue?", message: "why is the sky blue?"},
+		{name: "flags inside", text: "explain the --help flag", message: "explain the --help flag"},
+		{name: "flags before", text: "--model gpt-4 --precise what does rm --force do", message: "what does rm --force do", model: "gpt-4", precise: true},
+		{name: "as written", text: `say  "hi"  — to “them”`, message: `say  "hi"  — to “them”`},
+		{name: "bare dashes", text: "--precise -- --model is a flag", message: "--model is a flag", precise: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, err := parse("ask", test.text, words(test.text), params, nil)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if args.String("message") != test.message || args.String("model") != test.model || args.Bool("precise") != test.precise {
+				t.Fatalf("expected %q (model %q, precise %v), got %v", test.message, test.model, test.precise, args.values)
+			}
+		})
+	}
+
+	if _, err := parse("ask", "--precise", words("--precise"), params, nil); err == nil || !strings.Contains(err.Error(), "Missing argument `message`") {
+		t.Fatalf("expected the message to be missing, got %v", err)
+	}
+}
+
+func TestParseCaptures(t *testing.T) {
+	params := []Param{Arg("id", Int).Required(), Flag("scope", String)}
+
+	tests := []struct {
+		name     string
+		text     string
+		captures map[string]string
+		values   map[string][]string
+		err      bool
+	}{
+		{name: "from the trigger", text: "", captures: map[string]string{"id": "42"}, values: map[string][]string{"id": {"42"}}},
+		{name: "given wins", text: "7", captures: map[string]string{"id": "42"}, values: map[string][]string{"id": {"7"}}},
+		{name: "extra captures", text: "7", captures: map[string]string{"scope": "team"}, values: map[string][]string{"id": {"7"}, "scope": {"team"}}},
+		{name: "missing", text: "", captures: map[string]string{}, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, err := parse("test", test.text, words(test.text), params, test.captures)
+			if test.err != (err != nil) {
+				t.Fatalf("expected an error to be %v, got %v", test.err, err)
+			}
+			if !test.err && !reflect.DeepEqual(args.values, test.values) {
+				t.Fatalf("expected %v, got %v", test.values, args.values)
+			}
+		})
+	}
+}
+
+func TestUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []Param
+		usage  string
+	}{
+		{name: "none", params: []Param{}, usage: "test"},
+		{
+			name:   "flags last",
+			params: []Param{Flag("force", Bool), Arg("name", String).Required(), Flag("level", Int)},
+			usage:  "test <name> [--force] [--level <number>]",
+		},
+		{
+			name:   "variadic and rest",
+			params: []Param{Arg("users", User).Variadic(), Arg("message", String).Required().Rest()},
+			usage:  "test [<users>]... <message>...",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if res := usage("test", test.params); res != test.usage {
+				t.Fatalf("expected %q, got %q", test.usage, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/slack-go/slack"
+)
+
+// UserError is an error caused by the user, where the message is safe to show back to them
+type UserError struct {
+	Message string
+}
+
+func (e *UserError) Error() string {
+	return e.Message
+}
+
+// UserErrorf creates a UserError with a formatted message
+func UserErrorf(format string, a ...any) error {
+	return &UserError{Message: fmt.Sprintf(format, a...)}
+}
+
+// Failure is an error given back by a resolver, along with what is needed to present it
+type Failure struct {
+	// ID is the correlation ID, which is also written in the log line for the error
+	ID string
+
+	// Command is the full name of the action invoked (empty for a top level fallback)
+	Command string
+
+	// Err is the error itself
+	Err error
+}
+
+// IsUserError returns true if the failure was caused by the user rather than something going wrong internally
+func (f Failure) IsUserError() bool {
+	var user *UserError
+	var usage *UsageError
+	return errors.As(f.Err, &user) || errors.As(f.Err, &usage)
+}
+
+// Text is the plain text shown for the failure in notifications and clients without blocks, which like the
+// DefaultPresenter never gives away what went wrong internally
+func (f Failure) Text() string {
+	if f.IsUserError() {
+		return f.Err.Error()
+	}
+	return fmt.Sprintf("Something went wrong (ref %s)", f.ID)
+}
+
+// Presenter renders a failure as the blocks of a message
+type Presenter func(failure Failure) []slack.Block
+
+// DefaultPresenter shows user errors as they are, and only the correlation ID for internal failures
+func DefaultPresenter(failure Failure) []slack.Block {
+	var usage *UsageError
+	if errors.As(failure.Err, &usage) {
+		return []slack.Block{
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(
+					slack.MarkdownType,
+					fmt.Sprintf(":warning: %s", usage.Reason),
+					false,
+					false,
+				),
+				nil,
+				nil,
+			),
+			slack.NewContextBlock(
+				"",
+				slack.NewTextBlockObject(
+					slack.MarkdownType,
+					fmt.Sprintf("Usage: `%s`", usage.Usage),
+					false,
+					false,
+				),
+			),
+		}
+	}
+
+	if failure.IsUserError() {
+		return []slack.Block{
+			slack.NewSectionBlock(
+				slack.NewTextBlockObject(
+					slack.MarkdownType,
+					fmt.Sprintf(":warning: %s", failure.Err.Error()),
+					false,
+					false,
+				),
+				nil,
+				nil,
+			),
+		}
+	}
+
+	return []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(
+				slack.MarkdownType,
+				":x: Something went wrong on my side, please try again later",
+				false,
+				false,
+			),
+			nil,
+			nil,
+		),
+		slack.NewContextBlock(
+			"",
+			slack.NewTextBlockObject(
+				slack.MarkdownType,
+				fmt.Sprintf("Error ID: `%s`", failure.ID),
+				false,
+				false,
+			),
+		),
+	}
+}
+
+// correlationID generates a short random ID to match what the user sees with the logs
+func correlationID() string {
+	bytes := make([]byte, 4)
+	if _, err := rand.Read(bytes); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"fmt"
+	"strings"
+
+	"d-exclaimation.me/relax/lib/f"
+	"github.com/slack-go/slack"
+)
+
+// helpMessage renders every action registered under the path, or a single one if asked for
+func (r *ActionsRouter[C]) helpMessage(path []string, args []string) slack.MsgOption {
+	if len(args) > 0 {
+		route, ok := r.find(args[0])
+		if !ok {
+			return slack.MsgOptionBlocks(
+				slack.NewSectionBlock(
+					slack.NewTextBlockObject(
+						slack.MarkdownType,
+						fmt.Sprintf(
+							":warning: There is no command called `%s`, try `%s` to see all of them",
+							strings.Join(join(path, args[0]), " "),
+							strings.Join(append([]string{"help"}, path...), " "),
+						),
+						false,
+						false,
+					),
+					nil,
+					nil,
+				),
+			)
+		}
+		if route.router != nil {
+			return route.router.helpMessage(join(path, route.name), args[1:])
+		}
+		return slack.MsgOptionBlocks(route.helpBlocks(path)...)
+	}
+
+	blocks := []slack.Block{
+		slack.NewHeaderBlock(
+			slack.NewTextBlockObject(
+				slack.PlainTextType,
+				f.IfElse(
+					len(path) > 0,
+					fmt.Sprintf("Available %s commands", strings.Join(path, " ")),
+					"Available commands",
+				),
+				false,
+				false,
+			),
+		),
+	}
+
+	// Mounted routers can be invoked by themselves when they have a fallback
+	if len(path) > 0 && r.fallback != nil {
+		blocks = append(blocks, r.fallback.summaryBlock(path))
+	}
+
+	for _, route := range r.actions {
+		blocks = append(blocks, route.summaryBlock(path))
+	}
+
+	blocks = append(blocks,
+		slack.NewContextBlock(
+			"",
+			slack.NewTextBlockObject(
+				slack.MarkdownType,
+				fmt.Sprintf(
+					"Use `%s <command>` for more details about a command",
+					strings.Join(append([]string{"help"}, path...), " "),
+				),
+				false,
+				false,
+			),
+		),
+	)
+
+	return slack.MsgOptionBlocks(blocks...)
+}
+
+// find returns the action registered with the name or alias
+func (r *ActionsRouter[C]) find(name string) (Action[C], bool) {
+	name = strings.ToLower(name)
+	for _, route := range r.actions {
+		if route.name == name || f.IsMember(route.aliases, name) {
+			return route, true
+		}
+	}
+	return Action[C]{}, false
+}
+
+// summaryBlock renders the action as a short entry in the help message
+func (a Action[C]) summaryBlock(path []string) slack.Block {
+	lines := []string{
+		fmt.Sprintf("`%s`", a.usageAt(path)),
+	}
+	if a.description != "" {
+		lines = append(lines, a.description)
+	}
+	if len(a.aliases) > 0 {
+		lines = append(lines, fmt.Sprintf("_Also as %s_", a.aliasList()))
+	}
+	if a.router != nil {
+		lines = append(lines, fmt.Sprintf("_Commands: %s_", a.router.commandList()))
+	}
+
+	return slack.NewSectionBlock(
+		slack.NewTextBlockObject(
+			slack.MarkdownType,
+			f.Text(lines...),
+			false,
+			false,
+		),
+		nil,
+		nil,
+	)
+}
+
+// aliasList renders the aliases as a comma separated list
+func (a Action[C]) aliasList() string {
+	return f.Join(f.Map(a.aliases, func(alias string) string { return fmt.Sprintf("`%s`", alias) }), ", ")
+}
+
+// commandList renders the name of every action in the router as a comma separated list
+func (r *ActionsRouter[C]) commandList() string {
+	return f.Join(f.Map(r.actions, func(route Action[C]) string { return fmt.Sprintf("`%s`", route.name) }), ", ")
+}
+
+// helpBlocks renders the full details of the action
+func (a Action[C]) helpBlocks(path []string) []slack.Block {
+	lines := []string{
+		fmt.Sprintf("*%s*", strings.Join(join(path, a.name), " ")),
+	}
+	if a.description != "" {
+		lines = append(lines, a.description)
+	}
+	lines = append(lines, fmt.Sprintf("Usage: `%s`", a.usageAt(path)))
+	if len(a.aliases) > 0 {
+		lines = append(lines, fmt.Sprintf("Aliases: %s", a.aliasList()))
+	}
+
+	return []slack.Block{
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(
+				slack.MarkdownType,
+				f.Text(lines...),
+				false,
+				false,
+			),
+			nil,
+			nil,
+		),
+	}
+}
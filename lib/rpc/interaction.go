@@ -0,0 +1,242 @@
+package rpc
+
+import (
+	"strings"
+
+	"d-exclaimation.me/relax/lib/async"
+	"d-exclaimation.me/relax/lib/f"
+	"github.com/slack-go/slack"
+)
+
+// Interaction is an interactive component event, along with the block action that triggered it (if any)
+type Interaction struct {
+	// Callback is the interaction callback itself
+	Callback slack.InteractionCallback
+
+	// Action is the block action (e.g. button click) that triggered the interaction, nil for views and shortcuts
+	Action *slack.BlockAction
+
+	// Suffix is the rest of the action / callback ID after the prefix of the route
+	Suffix string
+}
+
+// Value returns the value of the button or the selected option that triggered the interaction
+func (i Interaction) Value() string {
+	if i.Action == nil {
+		return ""
+	}
+	if i.Action.SelectedOption.Value != "" {
+		return i.Action.SelectedOption.Value
+	}
+	return i.Action.Value
+}
+
+// Values returns the state of the inputs in the view (modal or home tab)
+func (i Interaction) Values() FormValues {
+	return FormValues(i.Callback.View.State.Values)
+}
+
+// FormValues is the state of the inputs in a view, keyed by block ID then action ID
+type FormValues map[string]map[string]slack.BlockAction
+
+// get returns the input for the block and action, if any
+func (v FormValues) get(block string, action string) slack.BlockAction {
+	return v[block][action]
+}
+
+// Text returns the value of a plain text input
+func (v FormValues) Text(block string, action string) string {
+	return v.get(block, action).Value
+}
+
+// User returns the selected user ID of a user select
+func (v FormValues) User(block string, action string) string {
+	return v.get(block, action).SelectedUser
+}
+
+// Users returns the selected user IDs of a multi user select
+func (v FormValues) Users(block string, action string) []string {
+	return v.get(block, action).SelectedUsers
+}
+
+// Channel returns the selected channel ID of a channel select
+func (v FormValues) Channel(block string, action string) string {
+	return v.get(block, action).SelectedChannel
+}
+
+// Conversation returns the selected conversation ID of a conversation select
+func (v FormValues) Conversation(block string, action string) string {
+	return v.get(block, action).SelectedConversation
+}
+
+// Option returns the value of the selected option of a static select or radio buttons
+func (v FormValues) Option(block string, action string) string {
+	return v.get(block, action).SelectedOption.Value
+}
+
+// Options returns the values of the selected options of a multi select or checkboxes
+func (v FormValues) Options(block string, action string) []string {
+	return f.Map(v.get(block, action).SelectedOptions, func(o slack.OptionBlockObject) string { return o.Value })
+}
+
+// Date returns the selected date (YYYY-MM-DD) of a date picker
+func (v FormValues) Date(block string, action string) string {
+	return v.get(block, action).SelectedDate
+}
+
+// InteractionResolver handles an interactive component event
+type InteractionResolver[C any] func(event Interaction, ctx C) error
+
+// InteractionRoute handles the interactions with a specific action ID (block actions) or callback ID (views and shortcuts)
+type InteractionRoute[C any] struct {
+	id       string
+	kind     slack.InteractionType
+	prefix   bool
+	resolver InteractionResolver[C]
+}
+
+// OnAction creates a route for a block action (e.g. button click, select) with the action ID
+func OnAction[C any](actionID string, resolver InteractionResolver[C]) InteractionRoute[C] {
+	return InteractionRoute[C]{
+		id:       actionID,
+		kind:     slack.InteractionTypeBlockActions,
+		resolver: resolver,
+	}
+}
+
+// OnSubmit creates a route for a view (modal) submission with the callback ID
+func OnSubmit[C any](callbackID string, resolver InteractionResolver[C]) InteractionRoute[C] {
+	return InteractionRoute[C]{
+		id:       callbackID,
+		kind:     slack.InteractionTypeViewSubmission,
+		resolver: resolver,
+	}
+}
+
+// OnClose creates a route for a view (modal) being closed with the callback ID
+func OnClose[C any](callbackID string, resolver InteractionResolver[C]) InteractionRoute[C] {
+	return InteractionRoute[C]{
+		id:       callbackID,
+		kind:     slack.InteractionTypeViewClosed,
+		resolver: resolver,
+	}
+}
+
+// Prefix makes the route match every ID starting with its ID, where the rest is given as the Suffix
+func (i InteractionRoute[C]) Prefix() InteractionRoute[C] {
+	i.prefix = true
+	return i
+}
+
+// matches returns true and the suffix if the route handles the ID
+func (i InteractionRoute[C]) matches(id string) (string, bool) {
+	if i.prefix && strings.HasPrefix(id, i.id) {
+		return id[len(i.id):], true
+	}
+	return "", id == i.id
+}
+
+// InteractionsRouter is a router for interactive components (block actions and views) of the bot's own messages,
+// and for the global and message shortcuts of the app
+type InteractionsRouter[C any] struct {
+	routes      []InteractionRoute[C]
+	responder   Responder[C]
+	presenter   Presenter
+	middlewares []Middleware[C]
+}
+
+// Interactions creates a new interactions router
+func Interactions[C any](routes ...InteractionRoute[C]) InteractionsRouter[C] {
+	return InteractionsRouter[C]{
+		routes: routes,
+	}
+}
+
+// Respond sets how the router posts its own messages (i.e. errors) back to the user
+func (r InteractionsRouter[C]) Respond(responder Responder[C]) InteractionsRouter[C] {
+	r.responder = responder
+	return r
+}
+
+// Catch sets how errors given back by the resolvers are shown to the user, instead of the DefaultPresenter
+func (r InteractionsRouter[C]) Catch(presenter Presenter) InteractionsRouter[C] {
+	r.presenter = presenter
+	return r
+}
+
+// Use adds middlewares that wrap every resolver in the router
+func (r InteractionsRouter[C]) Use(middlewares ...Middleware[C]) InteractionsRouter[C] {
+	r.middlewares = append(r.middlewares, middlewares...)
+	return r
+}
+
+// find returns the route that handles the ID, where exact routes win over the longest prefix
+func (r *InteractionsRouter[C]) find(kind slack.InteractionType, id string) (InteractionRoute[C], string, bool) {
+	best, suffix, found := InteractionRoute[C]{}, "", false
+	for _, route := range r.routes {
+		if route.kind != kind {
+			continue
+		}
+		rest, ok := route.matches(id)
+		if !ok {
+			continue
+		}
+		if !route.prefix {
+			return route, "", true
+		}
+		if !found || len(route.id) > len(best.id) {
+			best, suffix, found = route, rest, true
+		}
+	}
+	return best, suffix, found
+}
+
+// HandleAsync handles the interaction callback, for every block action in it, the view, or the shortcut itself
+func (r *InteractionsRouter[C]) HandleAsync(event slack.InteractionCallback, ctx func() C) async.Task[async.Unit] {
+	return async.New(func() (async.Unit, error) {
+		s := scope[C]{
+			responder:   r.responder,
+			presenter:   r.presenter,
+			middlewares: r.middlewares,
+		}
+
+		switch event.Type {
+		case slack.InteractionTypeBlockActions:
+			for _, action := range event.ActionCallback.BlockActions {
+				route, suffix, ok := r.find(event.Type, action.ActionID)
+				if !ok {
+					continue
+				}
+				r.run(s, route, Interaction{Callback: event, Action: action, Suffix: suffix}, action.ActionID, ctx)
+			}
+
+		case slack.InteractionTypeViewSubmission, slack.InteractionTypeViewClosed:
+			route, suffix, ok := r.find(event.Type, event.View.CallbackID)
+			if !ok {
+				return async.Done, nil
+			}
+			r.run(s, route, Interaction{Callback: event, Suffix: suffix}, event.View.CallbackID, ctx)
+
+		case slack.InteractionTypeShortcut, slack.InteractionTypeMessageAction:
+			route, suffix, ok := r.find(event.Type, event.CallbackID)
+			if !ok {
+				return async.Done, nil
+			}
+			r.run(s, route, Interaction{Callback: event, Suffix: suffix}, event.CallbackID, ctx)
+		}
+
+		return async.Done, nil
+	})
+}
+
+// run runs the route's resolver through the middlewares
+func (r *InteractionsRouter[C]) run(s scope[C], route InteractionRoute[C], event Interaction, id string, ctx func() C) {
+	c := ctx()
+	args := Args{command: id, text: event.Value()}
+	err := chain(s.middlewares, func(args Args, c C) error {
+		return route.resolver(event, c)
+	})(args, c)
+	if err != nil {
+		s.fail(c, args, err)
+	}
+}
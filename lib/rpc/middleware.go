@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps a resolver to add behaviour before and / or after it runs
+type Middleware[C any] func(next Resolver[C]) Resolver[C]
+
+// ErrForbidden is the error given back when a middleware refuses to run the resolver
+var ErrForbidden error = &UserError{Message: "You are not allowed to do that"}
+
+// ErrRateLimited is the error given back when too many actions were invoked in a short time
+var ErrRateLimited error = &UserError{Message: "Too many requests, try again in a bit"}
+
+// chain wraps the resolver with the middlewares, where the first middleware is the outermost one
+func chain[C any](middlewares []Middleware[C], resolver Resolver[C]) Resolver[C] {
+	res := resolver
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		res = middlewares[i](res)
+	}
+	return res
+}
+
+// Recover turns a panic in the resolver into an error instead of crashing the bot
+func Recover[C any]() Middleware[C] {
+	return func(next Resolver[C]) Resolver[C] {
+		return func(args Args, ctx C) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					log.Printf("%s panicked with %v\n%s", args.label(), p, debug.Stack())
+					err = fmt.Errorf("panic: %v", p)
+				}
+			}()
+			return next(args, ctx)
+		}
+	}
+}
+
+// Timing logs how long the resolver took to run
+func Timing[C any]() Middleware[C] {
+	return func(next Resolver[C]) Resolver[C] {
+		return func(args Args, ctx C) error {
+			start := time.Now()
+			err := next(args, ctx)
+			log.Printf("%s took %s\n", args.label(), time.Since(start))
+			return err
+		}
+	}
+}
+
+// Authorize only runs the resolver if the check passes, otherwise gives back ErrForbidden
+func Authorize[C any](check func(args Args, ctx C) bool) Middleware[C] {
+	return func(next Resolver[C]) Resolver[C] {
+		return func(args Args, ctx C) error {
+			if !check(args, ctx) {
+				return ErrForbidden
+			}
+			return next(args, ctx)
+		}
+	}
+}
+
+// RateLimit only allows a number of invocations per key (e.g. the user ID) in every window,
+// otherwise gives back ErrRateLimited
+func RateLimit[C any](limit int, window time.Duration, key func(args Args, ctx C) string) Middleware[C] {
+	type request struct {
+		key string
+		out chan bool
+	}
+
+	type bucket struct {
+		start time.Time
+		count int
+	}
+
+	requests := make(chan request)
+
+	// Actor to keep the buckets concurrent-safe
+	go func() {
+		buckets := make(map[string]bucket)
+		swept := time.Now()
+		for req := range requests {
+			// Forget the buckets that are no longer relevant every now and then
+			if time.Since(swept) > window {
+				for key, b := range buckets {
+					if time.Since(b.start) > window {
+						delete(buckets, key)
+					}
+				}
+				swept = time.Now()
+			}
+
+			b, ok := buckets[req.key]
+			if !ok || time.Since(b.start) > window {
+				b = bucket{start: time.Now()}
+			}
+			b.count++
+			buckets[req.key] = b
+			req.out <- b.count <= limit
+		}
+	}()
+
+	return func(next Resolver[C]) Resolver[C] {
+		return func(args Args, ctx C) error {
+			out := make(chan bool)
+			requests <- request{key: key(args, ctx), out: out}
+			if !<-out {
+				return ErrRateLimited
+			}
+			return next(args, ctx)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package rpc
+
+import "github.com/slack-go/slack"
+
+// OnShortcut creates a route for a global shortcut (from the shortcuts menu / search) with the callback ID
+func OnShortcut[C any](callbackID string, resolver InteractionResolver[C]) InteractionRoute[C] {
+	return InteractionRoute[C]{
+		id:       callbackID,
+		kind:     slack.InteractionTypeShortcut,
+		resolver: resolver,
+	}
+}
+
+// OnMessageShortcut creates a route for a message shortcut (from the "more actions" menu of a message) with the callback ID,
+// where the message is given as the Callback.Message of the interaction
+func OnMessageShortcut[C any](callbackID string, resolver InteractionResolver[C]) InteractionRoute[C] {
+	return InteractionRoute[C]{
+		id:       callbackID,
+		kind:     slack.InteractionTypeMessageAction,
+		resolver: resolver,
+	}
+}
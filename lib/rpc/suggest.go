@@ -0,0 +1,162 @@
+package rpc
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"d-exclaimation.me/relax/lib/f"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// SuggestionAction is the action ID of the button that runs the suggested command
+	SuggestionAction = "rpc-suggestion"
+
+	// SuggestionFallbackAction is the action ID of the button that ignores the suggestion and runs the fallback
+	SuggestionFallbackAction = "rpc-suggestion-fallback"
+
+	// maxButtonValue is the longest value a button can carry
+	maxButtonValue = 2000
+)
+
+// suggest returns the registered name closest to the word, if it is close enough to be a typo
+func (r *ActionsRouter[C]) suggest(word string) (string, bool) {
+	word = strings.ToLower(word)
+	if len(word) < 3 {
+		return "", false
+	}
+
+	names := []string{"help"}
+	for _, route := range r.actions {
+		if route.tier != exactTier && route.tier != prefixTier {
+			continue
+		}
+		names = append(names, route.name)
+		names = append(names, route.aliases...)
+	}
+
+	threshold := f.IfElse(len(word) <= 4, 1, 2)
+	best, distance := "", threshold+1
+	for _, name := range names {
+		d := f.EditDistance(word, name)
+		if d > 0 && d < distance {
+			best, distance = name, d
+		}
+	}
+
+	return best, best != ""
+}
+
+// fits returns true if the rest of the message would be taken as it is by the action, so only a mistyped command
+// is suggested rather than any message starting with a word close to one (e.g. "person A says" for `persona`)
+func (r *ActionsRouter[C]) fits(name string, text string) bool {
+	words := tokenize(text)
+	if name == "help" {
+		return len(words) == 0
+	}
+
+	action, ok := f.First(r.actions, func(a Action[C]) bool { return a.name == name || f.IsMember(a.aliases, name) })
+	if !ok {
+		return false
+	}
+	return action.fits(text, words)
+}
+
+// fits returns true if the action takes the words as its arguments, where a mounted router needs one of its own
+// actions (not the fallback) to take them, and an action without params takes none
+func (a Action[C]) fits(text string, words []token) bool {
+	if len(words) == 0 {
+		return true
+	}
+	if a.router != nil {
+		route, ok := a.router.route(text, words)
+		if !ok {
+			return false
+		}
+		rest := strings.TrimSpace(text[route.hit.offset:])
+		return route.action.fits(rest, tokenize(rest))
+	}
+	if a.params == nil {
+		return false
+	}
+	_, err := parse(a.name, text, f.Map(words, func(t token) string { return t.value }), a.params, nil)
+	return err == nil
+}
+
+// suggestionMessage renders the suggestion with buttons to run it, or to run the fallback if there is one
+func suggestionMessage(suggestion string, corrected string, original string, fallback bool) slack.MsgOption {
+	buttons := []slack.BlockElement{
+		slack.NewButtonBlockElement(
+			SuggestionAction,
+			corrected,
+			slack.NewTextBlockObject(
+				slack.PlainTextType,
+				truncate(fmt.Sprintf("Yes, run \"%s\"", corrected), 75),
+				false,
+				false,
+			),
+		).WithStyle(slack.StylePrimary),
+	}
+
+	if fallback {
+		buttons = append(buttons,
+			slack.NewButtonBlockElement(
+				SuggestionFallbackAction,
+				original,
+				slack.NewTextBlockObject(
+					slack.PlainTextType,
+					"No, go ahead",
+					false,
+					false,
+				),
+			),
+		)
+	}
+
+	return slack.MsgOptionBlocks(
+		slack.NewSectionBlock(
+			slack.NewTextBlockObject(
+				slack.MarkdownType,
+				fmt.Sprintf(":thinking_face: Did you mean `%s`?", suggestion),
+				false,
+				false,
+			),
+			nil,
+			nil,
+		),
+		slack.NewActionBlock("", buttons...),
+	)
+}
+
+// Interactions returns the routes for the buttons of the suggestion messages, to be added to an InteractionsRouter
+func (r *ActionsRouter[C]) Interactions() []InteractionRoute[C] {
+	answer := func(literal bool) InteractionResolver[C] {
+		return func(event Interaction, ctx C) error {
+			// The suggestion is no longer needed once answered
+			if event.Callback.ResponseURL != "" {
+				err := slack.PostWebhook(event.Callback.ResponseURL, &slack.WebhookMessage{DeleteOriginal: true})
+				if err != nil {
+					log.Printf("%s gives back %s\n", event.Action.ActionID, err.Error())
+				}
+			}
+
+			r.dispatch(scope[C]{literal: literal}, event.Value(), func() C { return ctx })
+			return nil
+		}
+	}
+
+	return []InteractionRoute[C]{
+		OnAction(SuggestionAction, answer(false)),
+		OnAction(SuggestionFallbackAction, answer(true)),
+	}
+}
+
+// truncate shortens the text to at most n characters
+func truncate(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n-1]) + "…"
+}
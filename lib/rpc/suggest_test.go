@@ -0,0 +1,81 @@
+package rpc
+
+import "testing"
+
+// router is a small set of actions alike the bot's own, to suggest from
+func router() ActionsRouter[struct{}] {
+	return Actions(
+		Exact("reviewer", nothing).Params(Flag("count", Int), Flag("exclude", User).Variadic()),
+		Exact("quote", nothing),
+		Exact("meme", nothing).Alias("memes"),
+		Exact("ask", nothing).Params(Arg("message", String).Required().Rest(), Flag("model", String)),
+		Prefix("hello", nothing),
+		Contains("thanks", nothing),
+		Mount("persona", Actions(
+			Exact("set", nothing).Params(Arg("name", String).Required()),
+			Exact("list", nothing),
+		)),
+	)
+}
+
+func TestSuggest(t *testing.T) {
+	tests := []struct {
+		word       string
+		suggestion string
+	}{
+		{word: "reviwer", suggestion: "reviewer"},
+		{word: "REVEIWER", suggestion: "reviewer"},
+		{word: "qoute", suggestion: "quote"},
+		{word: "mems", suggestion: "meme"},
+		{word: "halp", suggestion: "help"},
+		{word: "helo", suggestion: "help"},
+		{word: "persna", suggestion: "persona"},
+		{word: "reviewer", suggestion: ""},
+		{word: "asj", suggestion: "ask"},
+		{word: "as", suggestion: ""},
+		{word: "thank", suggestion: ""},
+		{word: "banana", suggestion: ""},
+	}
+
+	r := router()
+	for _, test := range tests {
+		t.Run(test.word, func(t *testing.T) {
+			suggestion, ok := r.suggest(test.word)
+			if ok != (test.suggestion != "") || suggestion != test.suggestion {
+				t.Fatalf("expected %q, got %q", test.suggestion, suggestion)
+			}
+		})
+	}
+}
+
+func TestFits(t *testing.T) {
+	tests := []struct {
+		name   string
+		action string
+		text   string
+		fits   bool
+	}{
+		{name: "help alone", action: "help", text: "", fits: true},
+		{name: "help with words", action: "help", text: "me please", fits: false},
+		{name: "no params", action: "quote", text: "", fits: true},
+		{name: "no params with words", action: "quote", text: "of the day", fits: false},
+		{name: "alias", action: "memes", text: "", fits: true},
+		{name: "flags", action: "reviewer", text: "--count 2 --exclude <@U012AB3CD>", fits: true},
+		{name: "invalid flags", action: "reviewer", text: "--count two", fits: false},
+		{name: "words for flags", action: "reviewer", text: "is on holiday", fits: false},
+		{name: "free text", action: "ask", text: "what is --help", fits: true},
+		{name: "mounted", action: "persona", text: "set pirate", fits: true},
+		{name: "mounted without an action", action: "persona", text: "A says hi", fits: false},
+		{name: "mounted with invalid arguments", action: "persona", text: "list all of them", fits: false},
+		{name: "unknown", action: "banana", text: "", fits: false},
+	}
+
+	r := router()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if fits := r.fits(test.action, test.text); fits != test.fits {
+				t.Fatalf("expected %v, got %v", test.fits, fits)
+			}
+		})
+	}
+}
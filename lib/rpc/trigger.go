@@ -0,0 +1,152 @@
+package rpc
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Tiers of triggers, where a lower tier wins when several actions match the same message
+const (
+	exactTier = iota
+	prefixTier
+	regexTier
+	containsTier
+)
+
+// hit is what a trigger gives back when it matches a message
+type hit struct {
+	// offset is where the arguments start in the text
+	offset int
+
+	// captures are the parameters captured by the trigger
+	captures map[string]string
+
+	// weight is how specific the match is, where a higher weight wins within the same tier
+	weight int
+}
+
+// trigger checks if a message starts with an invocation of the name (or pattern)
+type trigger func(name string, text string, words []token) (hit, bool)
+
+// rest returns the offset of the words after the first one
+func rest(text string, words []token) int {
+	if len(words) < 2 {
+		return len(text)
+	}
+	return words[1].start
+}
+
+// exactly matches the first word with the name, ignoring case
+func exactly(name string, text string, words []token) (hit, bool) {
+	if len(words) < 1 || strings.ToLower(words[0].value) != name {
+		return hit{}, false
+	}
+	return hit{offset: rest(text, words), weight: len(name)}, true
+}
+
+// prefixed matches the first word starting with the name, and captures the rest of the word as `suffix`
+func prefixed(name string, text string, words []token) (hit, bool) {
+	// Compare the same bytes as what is sliced off, since lowercasing can change the length of non-ASCII words
+	if len(words) < 1 || len(words[0].value) < len(name) || !strings.EqualFold(words[0].value[:len(name)], name) {
+		return hit{}, false
+	}
+	return hit{
+		offset:   rest(text, words),
+		captures: map[string]string{"suffix": words[0].value[len(name):]},
+		weight:   len(name),
+	}, true
+}
+
+// containing matches the first word containing the name anywhere, ignoring case
+func containing(name string, text string, words []token) (hit, bool) {
+	if len(words) < 1 || !strings.Contains(strings.ToLower(words[0].value), name) {
+		return hit{}, false
+	}
+	return hit{offset: rest(text, words), weight: len(name)}, true
+}
+
+// matching creates a trigger for the patterns, which are compiled once when the action and its aliases are created
+func matching(patterns map[string]*regexp.Regexp) trigger {
+	return func(pattern string, text string, words []token) (hit, bool) {
+		re, ok := patterns[pattern]
+		if !ok {
+			return hit{}, false
+		}
+		return captured(re, text)
+	}
+}
+
+// captured matches the text with the regular expression, and captures every group by their name (or index if unnamed)
+func captured(re *regexp.Regexp, text string) (hit, bool) {
+	indices := re.FindStringSubmatchIndex(text)
+	if indices == nil {
+		return hit{}, false
+	}
+
+	captures := make(map[string]string)
+	for i, name := range re.SubexpNames() {
+		if i == 0 || indices[2*i] < 0 {
+			continue
+		}
+		if name == "" {
+			name = strconv.Itoa(i)
+		}
+		captures[name] = text[indices[2*i]:indices[2*i+1]]
+	}
+
+	return hit{
+		offset:   indices[1],
+		captures: captures,
+		weight:   indices[1] - indices[0],
+	}, true
+}
+
+// candidate is an action that matches a message, along with how well it matches
+type candidate[C any] struct {
+	action Action[C]
+	hit    hit
+	index  int
+}
+
+// beats returns true if the candidate should be picked over the other one
+func (c candidate[C]) beats(other candidate[C]) bool {
+	switch {
+	case c.action.priority != other.action.priority:
+		return c.action.priority > other.action.priority
+	case c.action.tier != other.action.tier:
+		return c.action.tier < other.action.tier
+	case c.hit.weight != other.hit.weight:
+		return c.hit.weight > other.hit.weight
+	}
+	return c.index < other.index
+}
+
+// match returns the action that best matches the text, if any
+func (a Action[C]) match(text string, words []token) (hit, bool) {
+	best, found := hit{}, false
+	for _, name := range append([]string{a.name}, a.aliases...) {
+		h, ok := a.trigger(name, text, words)
+		if ok && (!found || h.weight > best.weight) {
+			best, found = h, true
+		}
+	}
+	return best, found
+}
+
+// route finds the action that best matches the text, by priority, then tier, then weight,
+// and finally the order they were registered
+func (r *ActionsRouter[C]) route(text string, words []token) (candidate[C], bool) {
+	best, found := candidate[C]{}, false
+	for i, action := range r.actions {
+		h, ok := action.match(text, words)
+		if !ok {
+			continue
+		}
+		c := candidate[C]{action: action, hit: h, index: i}
+		if !found || c.beats(best) {
+			best, found = c, true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,145 @@
+package rpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+// nothing is a resolver for the actions only routed to, never run
+func nothing(args Args, ctx struct{}) error {
+	return nil
+}
+
+func TestRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []Action[struct{}]
+		text    string
+		picked  string
+		rest    string
+	}{
+		{
+			name:    "exact over prefix",
+			actions: []Action[struct{}]{Prefix("help", nothing), Exact("help", nothing)},
+			text:    "help me",
+			picked:  "help",
+			rest:    "me",
+		},
+		{
+			name:    "prefix over regex",
+			actions: []Action[struct{}]{Regex(`^hel+o`, nothing), Prefix("hel", nothing)},
+			text:    "hello there",
+			picked:  "hel",
+			rest:    "there",
+		},
+		{
+			name:    "regex over contains",
+			actions: []Action[struct{}]{Contains("ell", nothing), Regex(`^hel+o`, nothing)},
+			text:    "hello there",
+			picked:  `^hel+o`,
+			rest:    " there",
+		},
+		{
+			name:    "longer prefix in the same tier",
+			actions: []Action[struct{}]{Prefix("re", nothing), Prefix("review", nothing)},
+			text:    "reviewer",
+			picked:  "review",
+		},
+		{
+			name:    "first registered on a tie",
+			actions: []Action[struct{}]{Contains("ab", nothing), Contains("bc", nothing)},
+			text:    "abc",
+			picked:  "ab",
+		},
+		{
+			name:    "priority over tier",
+			actions: []Action[struct{}]{Exact("help", nothing), Contains("el", nothing).Priority(1)},
+			text:    "help",
+			picked:  "el",
+		},
+		{
+			name:    "alias",
+			actions: []Action[struct{}]{Exact("memes", nothing), Exact("meme", nothing).Alias("mème")},
+			text:    "MÈME now",
+			picked:  "meme",
+			rest:    "now",
+		},
+		{
+			name:    "nothing matches",
+			actions: []Action[struct{}]{Exact("help", nothing)},
+			text:    "hello",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := Actions(test.actions...)
+			c, ok := router.route(test.text, tokenize(test.text))
+			if !ok {
+				if test.picked != "" {
+					t.Fatalf("expected %q to be picked, got nothing", test.picked)
+				}
+				return
+			}
+			if c.action.name != test.picked {
+				t.Fatalf("expected %q to be picked, got %q", test.picked, c.action.name)
+			}
+			if rest := test.text[c.hit.offset:]; rest != test.rest {
+				t.Fatalf("expected the arguments %q, got %q", test.rest, rest)
+			}
+		})
+	}
+}
+
+func TestTriggerCaptures(t *testing.T) {
+	tests := []struct {
+		name     string
+		trigger  trigger
+		pattern  string
+		text     string
+		captures map[string]string
+		ok       bool
+	}{
+		{name: "prefix suffix", trigger: prefixed, pattern: "hello", text: "helloworld again", captures: map[string]string{"suffix": "world"}, ok: true},
+		{name: "prefix ignoring case", trigger: prefixed, pattern: "hello", text: "HELLO", captures: map[string]string{"suffix": ""}, ok: true},
+		{name: "prefix non-ASCII", trigger: prefixed, pattern: "héllo", text: "HÉLLOwörld", captures: map[string]string{"suffix": "wörld"}, ok: true},
+		{name: "prefix shorter word", trigger: prefixed, pattern: "hello", text: "hell", ok: false},
+		{name: "prefix changing length", trigger: prefixed, pattern: "k", text: "\u212Aelvin", ok: false},
+		{
+			name:     "regex groups",
+			trigger:  Regex(`^pr(?P<id>\d+)(?:/(\w+))?`, nothing).trigger,
+			pattern:  `^pr(?P<id>\d+)(?:/(\w+))?`,
+			text:     "pr42/files please",
+			captures: map[string]string{"id": "42", "2": "files"},
+			ok:       true,
+		},
+		{
+			name:     "regex unmatched group",
+			trigger:  Regex(`^pr(?P<id>\d+)(?:/(\w+))?`, nothing).trigger,
+			pattern:  `^pr(?P<id>\d+)(?:/(\w+))?`,
+			text:     "pr7",
+			captures: map[string]string{"id": "7"},
+			ok:       true,
+		},
+		{
+			name:     "regex alias",
+			trigger:  Regex(`^pr(?P<id>\d+)`, nothing).Alias(`^mr!(?P<id>\d+)`).trigger,
+			pattern:  `^mr!(?P<id>\d+)`,
+			text:     "mr!9",
+			captures: map[string]string{"id": "9"},
+			ok:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, ok := test.trigger(test.pattern, test.text, tokenize(test.text))
+			if ok != test.ok {
+				t.Fatalf("expected a match to be %v, got %v", test.ok, ok)
+			}
+			if ok && !reflect.DeepEqual(h.captures, test.captures) {
+				t.Fatalf("expected %v, got %v", test.captures, h.captures)
+			}
+		})
+	}
+}
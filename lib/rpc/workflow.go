@@ -83,8 +83,9 @@ func (s Workflow[C]) OnExecute(execute func(e *slackevents.WorkflowStepExecuteEv
 
 // WorkflowsRouter is a router for workflows
 type WorkflowsRouter[C any] struct {
-	client *slack.Client
-	steps  []Workflow[C]
+	client      *slack.Client
+	steps       []Workflow[C]
+	middlewares []Middleware[C]
 }
 
 // Workflows creates a new workflows router
@@ -98,6 +99,12 @@ func Workflows[C any](
 	}
 }
 
+// Use adds middlewares that wrap every workflow step callback
+func (r WorkflowsRouter[C]) Use(middlewares ...Middleware[C]) WorkflowsRouter[C] {
+	r.middlewares = append(r.middlewares, middlewares...)
+	return r
+}
+
 // HandleAsync handles the workflow step execute event
 func (r *WorkflowsRouter[C]) HandleAsync(
 	event *slackevents.WorkflowStepExecuteEvent,
@@ -106,19 +113,34 @@ func (r *WorkflowsRouter[C]) HandleAsync(
 	return async.New(func() (async.Unit, error) {
 		for _, step := range r.steps {
 			if step.callbackID == event.CallbackID {
-				err := error(nil)
-				switch result := step.execute(event, ctx()).(type) {
-				case WorkflowSuccessResult:
-					err = r.client.WorkflowStepCompleted(
-						event.WorkflowStep.WorkflowStepExecuteID,
-						slack.WorkflowStepCompletedRequestOptionOutput(
-							result.Outputs,
-						),
-					)
-				case WorkflowFailureResult:
+				settled := false
+				resolver := chain(r.middlewares, func(args Args, c C) error {
+					err := error(nil)
+					switch result := step.execute(event, c).(type) {
+					case WorkflowSuccessResult:
+						err = r.client.WorkflowStepCompleted(
+							event.WorkflowStep.WorkflowStepExecuteID,
+							slack.WorkflowStepCompletedRequestOptionOutput(
+								result.Outputs,
+							),
+						)
+					case WorkflowFailureResult:
+						err = r.client.WorkflowStepFailed(
+							event.WorkflowStep.WorkflowStepExecuteID,
+							result.Message,
+						)
+					}
+					settled = true
+					return err
+				})
+
+				err := resolver(Args{command: event.CallbackID}, ctx())
+
+				// Make sure the workflow doesn't hang if a middleware stopped the step from running
+				if err != nil && !settled {
 					err = r.client.WorkflowStepFailed(
 						event.WorkflowStep.WorkflowStepExecuteID,
-						result.Message,
+						err.Error(),
 					)
 				}
 
@@ -147,28 +169,33 @@ func (r *WorkflowsRouter[C]) HandleInteractionAsync(
 				if step.callbackID != event.CallbackID {
 					continue
 				}
-				res := step.edit(event, c)
-				_, err = r.client.OpenView(
-					event.TriggerID,
-					slack.NewConfigurationModalRequest(
-						slack.Blocks{
-							BlockSet: res,
-						},
-						step.callbackID,
-						"",
-					).ModalViewRequest,
-				)
+				err = chain(r.middlewares, func(args Args, c C) error {
+					res := step.edit(event, c)
+					_, err := r.client.OpenView(
+						event.TriggerID,
+						slack.NewConfigurationModalRequest(
+							slack.Blocks{
+								BlockSet: res,
+							},
+							step.callbackID,
+							"",
+						).ModalViewRequest,
+					)
+					return err
+				})(Args{command: step.callbackID}, c)
 
 			case slack.InteractionTypeViewSubmission:
 				if event.View.PrivateMetadata != step.callbackID {
 					continue
 				}
-				res := step.save(event, c)
-				err = r.client.SaveWorkflowStepConfiguration(
-					event.WorkflowStep.WorkflowStepEditID,
-					res.In,
-					res.Out,
-				)
+				err = chain(r.middlewares, func(args Args, c C) error {
+					res := step.save(event, c)
+					return r.client.SaveWorkflowStepConfiguration(
+						event.WorkflowStep.WorkflowStepEditID,
+						res.In,
+						res.Out,
+					)
+				})(Args{command: step.callbackID}, c)
 			}
 
 			if err != nil {
@@ -0,0 +1,107 @@
+package source
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"d-exclaimation.me/relax/lib/kv"
+)
+
+// deduplicated drops the events already delivered, which Slack retries when the acknowledgement is slow
+type deduplicated struct {
+	Source
+	events chan Event
+	ttl    time.Duration
+	seen   func(id string) bool
+}
+
+// Deduplicate wraps the source to drop the events with an ID already delivered in the TTL,
+// remembered in the KV store, or in memory if the KV store is unavailable
+func Deduplicate(src Source, ttl time.Duration) Source {
+	return &deduplicated{
+		Source: src,
+		events: make(chan Event),
+		ttl:    ttl,
+		seen:   remember(ttl),
+	}
+}
+
+func (d *deduplicated) Events() <-chan Event {
+	return d.events
+}
+
+func (d *deduplicated) Run(ctx context.Context) error {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-d.Source.Events():
+				if e.ID != "" && d.duplicate(e.ID) {
+					log.Printf("Dropping duplicate delivery of %s\n", e.ID)
+					continue
+				}
+
+				select {
+				case d.events <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return d.Source.Run(ctx)
+}
+
+// duplicate returns true if the ID was already delivered in the TTL, and remembers it otherwise
+func (d *deduplicated) duplicate(id string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	fresh, err := kv.SetNX("events:"+id, 1, d.ttl).AwaitContext(ctx)
+	if err != nil {
+		return d.seen(id)
+	}
+	return !fresh
+}
+
+// remember creates an in-memory fallback that returns true if the ID was already given in the TTL
+func remember(ttl time.Duration) func(id string) bool {
+	type request struct {
+		id  string
+		out chan bool
+	}
+
+	requests := make(chan request)
+
+	// Actor to keep the IDs concurrent-safe
+	go func() {
+		ids := make(map[string]time.Time)
+		swept := time.Now()
+		for req := range requests {
+			// Forget the IDs that are no longer relevant every now and then
+			if time.Since(swept) > ttl {
+				for id, at := range ids {
+					if time.Since(at) > ttl {
+						delete(ids, id)
+					}
+				}
+				swept = time.Now()
+			}
+
+			at, ok := ids[req.id]
+			seen := ok && time.Since(at) <= ttl
+			if !seen {
+				ids[req.id] = time.Now()
+			}
+			req.out <- seen
+		}
+	}()
+
+	return func(id string) bool {
+		out := make(chan bool)
+		requests <- request{id: id, out: out}
+		return <-out
+	}
+}
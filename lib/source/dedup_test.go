@@ -0,0 +1,160 @@
+package source
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// end marks the last event given by the fake source
+const end = "end"
+
+// fakeSource gives the events it is told to, as if they came from Slack
+type fakeSource struct {
+	events chan Event
+}
+
+func (s *fakeSource) Events() <-chan Event {
+	return s.events
+}
+
+func (s *fakeSource) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// fakeKV imitates the SET NX command of the KV store, or fails every command if unavailable
+func fakeKV(t *testing.T, available bool) {
+	mu := sync.Mutex{}
+	keys := map[string]bool{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !available {
+			json.NewEncoder(w).Encode(map[string]any{"error": "unavailable"})
+			return
+		}
+
+		command := []any{}
+		if err := json.NewDecoder(r.Body).Decode(&command); err != nil || len(command) < 2 || command[0] != "SET" {
+			json.NewEncoder(w).Encode(map[string]any{"error": "unknown command"})
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		key := command[1].(string)
+		if keys[key] {
+			json.NewEncoder(w).Encode(map[string]any{"result": nil})
+			return
+		}
+		keys[key] = true
+		json.NewEncoder(w).Encode(map[string]any{"result": "OK"})
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("KV_URL", srv.URL)
+	t.Setenv("KV_TOKEN", "token")
+}
+
+// delivered gives the events with the IDs through the deduplicated source, and returns the IDs of the ones let through
+func delivered(t *testing.T, ids []string) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	src := &fakeSource{events: make(chan Event)}
+	dedup := Deduplicate(src, time.Minute)
+	go dedup.Run(ctx)
+
+	go func() {
+		events := make([]Event, 0, len(ids)+1)
+		for _, id := range ids {
+			events = append(events, Event{ID: id, Data: id})
+		}
+		for _, e := range append(events, Event{Data: end}) {
+			select {
+			case src.events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	res := []string{}
+	for {
+		select {
+		case e := <-dedup.Events():
+			if e.Data == end {
+				return res
+			}
+			res = append(res, e.ID)
+		case <-ctx.Done():
+			t.Fatalf("only %v were delivered in time", res)
+		}
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	tests := []struct {
+		name      string
+		ids       []string
+		delivered []string
+	}{
+		{name: "unique", ids: []string{"Ev1", "Ev2", "Ev3"}, delivered: []string{"Ev1", "Ev2", "Ev3"}},
+		{name: "retried", ids: []string{"Ev1", "Ev1", "Ev2", "Ev1", "Ev2"}, delivered: []string{"Ev1", "Ev2"}},
+		{name: "retried later", ids: []string{"Ev1", "Ev2", "Ev3", "Ev2"}, delivered: []string{"Ev1", "Ev2", "Ev3"}},
+		{name: "without an ID", ids: []string{"Ev1", "", "Ev1", ""}, delivered: []string{"Ev1", "", ""}},
+	}
+
+	for _, available := range []bool{true, false} {
+		for _, test := range tests {
+			name := test.name + map[bool]string{true: " in KV", false: " in memory"}[available]
+			t.Run(name, func(t *testing.T) {
+				fakeKV(t, available)
+				if res := delivered(t, test.ids); !reflect.DeepEqual(res, test.delivered) {
+					t.Fatalf("expected %v to be delivered, got %v", test.delivered, res)
+				}
+			})
+		}
+	}
+}
+
+func TestIdentify(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     any
+		fallback string
+		id       string
+	}{
+		{
+			name:     "event callback",
+			data:     slackevents.EventsAPIEvent{Data: &slackevents.EventsAPICallbackEvent{EventID: "Ev1"}},
+			fallback: "envelope",
+			id:       "Ev1",
+		},
+		{
+			name:     "event without an ID",
+			data:     slackevents.EventsAPIEvent{Data: &slackevents.EventsAPICallbackEvent{}},
+			fallback: "envelope",
+			id:       "envelope",
+		},
+		{name: "interaction", data: slack.InteractionCallback{TriggerID: "123.456"}, fallback: "envelope", id: "123.456"},
+		{name: "interaction without a trigger", data: slack.InteractionCallback{}, fallback: "", id: ""},
+		{name: "slash command", data: slack.SlashCommand{TriggerID: "789.012"}, fallback: "", id: "789.012"},
+		{name: "anything else", data: "hello", fallback: "envelope", id: "envelope"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if id := identify(test.data, test.fallback); id != test.id {
+				t.Fatalf("expected %q, got %q", test.id, id)
+			}
+		})
+	}
+}
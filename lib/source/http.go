@@ -0,0 +1,174 @@
+package source
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// server receives the events as requests from Slack's HTTP Events API
+type server struct {
+	addr   string
+	secret string
+	events chan Event
+}
+
+// HTTP creates a source using Slack's HTTP Events API, listening on the address with the endpoints:
+//   - /slack/events for the Events API (including the URL verification challenge)
+//   - /slack/interactivity for the interactive components and shortcuts
+//   - /slack/commands for the slash commands
+//
+// Every request is verified with the app's signing secret.
+// https://api.slack.com/apis/connections/events-api
+func HTTP(addr string, secret string) Source {
+	return &server{
+		addr:   addr,
+		secret: secret,
+		events: make(chan Event),
+	}
+}
+
+func (s *server) Events() <-chan Event {
+	return s.events
+}
+
+func (s *server) Run(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slack/events", s.verified(s.handleEvents))
+	mux.HandleFunc("/slack/interactivity", s.verified(s.handleInteractivity))
+	mux.HandleFunc("/slack/commands", s.verified(s.handleCommands))
+
+	srv := &http.Server{
+		Addr:              s.addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext:       func(_ net.Listener) context.Context { return ctx },
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	log.Printf("Listening to Slack with the HTTP Events API on %s...\n", s.addr)
+
+	err := srv.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// verified only lets through the requests signed by Slack, where the body is given already read
+func (s *server) verified(handler func(w http.ResponseWriter, r *http.Request, body []byte)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		verifier, err := slack.NewSecretsVerifier(r.Header, s.secret)
+		if err != nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body, err := io.ReadAll(io.TeeReader(r.Body, &verifier))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := verifier.Ensure(); err != nil {
+			log.Printf("Rejecting unverified request to %s: %s\n", r.URL.Path, err.Error())
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// Allow the handlers to parse the form as usual
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		handler(w, r, body)
+	}
+}
+
+// ack acknowledges the request to Slack right away, before the event is handed off to the consumer which can be slow to take it
+func ack(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusOK)
+	if flusher, ok := w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// emit gives the event to the consumer, unless the request is gone before it is taken
+func (s *server) emit(r *http.Request, event Event) {
+	select {
+	case s.events <- event:
+	case <-r.Context().Done():
+	}
+}
+
+// handleEvents handles the Events API callbacks and the URL verification challenge
+func (s *server) handleEvents(w http.ResponseWriter, r *http.Request, body []byte) {
+	event, err := slackevents.ParseEvent(json.RawMessage(body), slackevents.OptionNoVerifyToken())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	switch event.Type {
+	case slackevents.URLVerification:
+		var challenge slackevents.ChallengeResponse
+		if err := json.Unmarshal(body, &challenge); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(challenge.Challenge))
+
+	case slackevents.CallbackEvent:
+		// Make sure Slack knows we acknowledge the event
+		ack(w)
+		s.emit(r, Event{ID: identify(event, ""), Data: event})
+
+	default:
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// handleInteractivity handles the interactive components, views, and shortcuts
+func (s *server) handleInteractivity(w http.ResponseWriter, r *http.Request, body []byte) {
+	var callback slack.InteractionCallback
+	if err := json.Unmarshal([]byte(r.FormValue("payload")), &callback); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Make sure Slack knows we acknowledge the event
+	ack(w)
+	s.emit(r, Event{ID: identify(callback, ""), Data: callback})
+}
+
+// handleCommands handles the slash commands
+func (s *server) handleCommands(w http.ResponseWriter, r *http.Request, body []byte) {
+	command, err := slack.SlashCommandParse(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Make sure Slack knows we acknowledge the event
+	ack(w)
+	s.emit(r, Event{ID: identify(command, ""), Data: command})
+}
@@ -0,0 +1,46 @@
+package source
+
+import (
+	"context"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+)
+
+// Event is an event received from Slack, already acknowledged by the source
+type Event struct {
+	// ID identifies the delivery, which stays the same when Slack retries it (empty if there is none)
+	ID string
+
+	// Data is either a slackevents.EventsAPIEvent, a slack.InteractionCallback, or a slack.SlashCommand
+	Data any
+}
+
+// Source is where the events from Slack come from (e.g. Socket Mode, HTTP Events API, or recorded events)
+type Source interface {
+	// Events gives every event received from Slack
+	Events() <-chan Event
+
+	// Run starts receiving events, and blocks until the context is cancelled or the source fails
+	Run(ctx context.Context) error
+}
+
+// identify gives the ID of the delivery, where the event ID of the Events API, or the trigger ID of
+// an interaction or a slash command, wins over the fallback (e.g. envelope ID)
+func identify(data any, fallback string) string {
+	id := ""
+	switch data := data.(type) {
+	case slackevents.EventsAPIEvent:
+		if callback, ok := data.Data.(*slackevents.EventsAPICallbackEvent); ok {
+			id = callback.EventID
+		}
+	case slack.InteractionCallback:
+		id = data.TriggerID
+	case slack.SlashCommand:
+		id = data.TriggerID
+	}
+	if id == "" {
+		return fallback
+	}
+	return id
+}
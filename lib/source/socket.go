@@ -0,0 +1,78 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// socket receives the events using Slack's Socket Mode (WebSocket / Realtime connecion)
+type socket struct {
+	conn   *socketmode.Client
+	events chan Event
+}
+
+// Socket creates a source using Slack's Socket Mode (WebSocket / Realtime connecion)
+// https://api.slack.com/apis/connections/socket
+// SocketMode usually provides faster response times than the Web Events API,
+// and it doesn't require a public endpoint.
+func Socket(client *slack.Client) Source {
+	return &socket{
+		conn:   socketmode.New(client),
+		events: make(chan Event),
+	}
+}
+
+func (s *socket) Events() <-chan Event {
+	return s.events
+}
+
+func (s *socket) Run(ctx context.Context) error {
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-s.conn.Events:
+				switch e.Type {
+
+				// Connecting to Slack
+				case socketmode.EventTypeConnecting:
+					log.Println("Connecting to Slack with Socket Mode...")
+
+				// Connection error
+				case socketmode.EventTypeConnectionError:
+					log.Println("Connection failed. Retrying later...")
+
+				// Connected to Slack
+				case socketmode.EventTypeConnected:
+					log.Println("Connected to Slack with Socket Mode.")
+
+				// Events, interactive components, and slash commands from Slack
+				case socketmode.EventTypeEventsAPI, socketmode.EventTypeInteractive, socketmode.EventTypeSlashCommand:
+					if e.Request == nil {
+						continue
+					}
+
+					// Make sure Slack knows we acknowledge the event
+					s.conn.Ack(*e.Request)
+
+					select {
+					case s.events <- Event{ID: identify(e.Data, e.Request.EnvelopeID), Data: e.Data}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	err := s.conn.RunContext(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
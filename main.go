@@ -1,34 +1,74 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"d-exclaimation.me/relax/app"
 	"d-exclaimation.me/relax/app/ai"
 	"d-exclaimation.me/relax/config"
 	"d-exclaimation.me/relax/lib/async"
+	"d-exclaimation.me/relax/lib/kv"
+	"d-exclaimation.me/relax/lib/source"
 	"github.com/slack-go/slack"
 )
 
 func main() {
 	config.Env.Load()
 
+	// Stop gracefully on Ctrl+C or when the container is stopped
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	client := slack.New(
 		config.Env.OAuth(),
 		slack.OptionAppLevelToken(config.Env.OAuthApp()),
 	)
 
-	ai := ai.New(config.Env.AIToken())
+	// Where the AI conversations are kept, in memory unless configured otherwise
+	var store ai.Store
+	switch config.Env.AIStore() {
+	case "kv":
+		store = ai.KV()
+	default:
+		store = ai.Memory()
+	}
+
+	ai := ai.New(config.Env.AIToken(), store)
+
+	// Where the events come from, Socket Mode unless configured otherwise
+	var src source.Source
+	switch config.Env.EventSource() {
+	case "http":
+		src = source.HTTP(":"+config.Env.Port(), config.Env.SigningSecret())
+	default:
+		src = source.Socket(client)
+	}
+
+	// Slack retries the events when the acknowledgement is slow, which should only be handled once
+	src = source.Deduplicate(src, 10*time.Minute)
 
 	task1 := async.New(func() (async.Unit, error) {
-		app.Listen(client, ai)
-		return async.Done, nil
+		return async.Done, app.Listen(ctx, client, ai, src)
 	})
 
 	errors := async.AwaitAllUnit(
 		task1,
 	)
 
+	// Make sure the writes that are never awaited (e.g. review counts) are not lost
+	flush, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := kv.Flush(flush); err != nil {
+		log.Println("Some writes to the KV store did not finish in time")
+	}
+
+	log.Println("Shut down gracefully")
+
 	for _, err := range errors {
 		if err != nil {
 			log.Fatalf("error: %s", err)